@@ -0,0 +1,107 @@
+package cache_go
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatchStore is implemented by backends that can write and delete many keys
+// in a few round trips. A non-positive ttl means no expiry. Keys that fail
+// are reported in a *BatchError; the others are written.
+type BatchStore interface {
+	StoreMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error
+	DeleteMany(ctx context.Context, keys []string) error
+}
+
+// BatchError reports the keys a batch operation failed on.
+type BatchError struct {
+	// Errors maps each failed key to its error.
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	keys := sortedKeys(e.Errors)
+	if len(keys) == 1 {
+		return fmt.Sprintf("cache_go: batch failed for key %q: %v", keys[0], e.Errors[keys[0]])
+	}
+	return fmt.Sprintf("cache_go: batch failed for %d keys, first %q: %v", len(keys), keys[0], e.Errors[keys[0]])
+}
+
+// Unwrap lets errors.Is and errors.As look at every key's error.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, key := range sortedKeys(e.Errors) {
+		errs = append(errs, e.Errors[key])
+	}
+	return errs
+}
+
+// batchErrors collects per-key errors, and returns nil or a *BatchError.
+type batchErrors map[string]error
+
+func (b batchErrors) err() error {
+	if len(b) == 0 {
+		return nil
+	}
+	return &BatchError{Errors: b}
+}
+
+// Result is the outcome of reading one key in a batch.
+type Result struct {
+	Value []byte
+	Found bool
+}
+
+// BatchGetter is implemented by backends that read many keys at once with
+// the same semantics everywhere.
+type BatchGetter interface {
+	// GetMany returns one Result per key, in order.
+	GetMany(ctx context.Context, keys []string) ([]Result, error)
+}
+
+// GetMany reads keys from repo, using its GetMany when it is a BatchGetter
+// and converting ValuesByKeys otherwise. It returns one Result per key, in
+// order.
+func GetMany(ctx context.Context, repo CacheRepo, keys []string) ([]Result, error) {
+	if getter, ok := repo.(BatchGetter); ok {
+		return getter.GetMany(ctx, keys)
+	}
+	if len(keys) == 0 {
+		return []Result{}, nil
+	}
+
+	values, err := repo.ValuesByKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(keys) {
+		return nil, fmt.Errorf("cache_go: ValuesByKeys returned %d values for %d keys", len(values), len(keys))
+	}
+
+	results := make([]Result, len(keys))
+	for i, v := range values {
+		switch value := v.(type) {
+		case nil:
+		case []byte:
+			results[i] = Result{Value: value, Found: true}
+		case string:
+			results[i] = Result{Value: []byte(value), Found: true}
+		default:
+			return nil, fmt.Errorf("cache_go: unexpected %T value for key %q", v, keys[i])
+		}
+	}
+	return results, nil
+}
+
+// valuesOf converts results to the ValuesByKeys form: the value, or nil for
+// missing keys.
+func valuesOf(results []Result) []interface{} {
+	values := make([]interface{}, len(results))
+	for i, r := range results {
+		if r.Found {
+			values[i] = r.Value
+		}
+	}
+	return values
+}
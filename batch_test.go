@@ -0,0 +1,148 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBatchStore runs the BatchStore behaviour shared by every backend.
+func testBatchStore(t *testing.T, store BatchStore, repo CacheRepo) {
+	ctx := context.Background()
+
+	items := make(map[string][]byte)
+	keys := make([]string, 0, 1500)
+	for i := 0; i < 1500; i++ {
+		key := fmt.Sprintf("batch:%d", i)
+		items[key] = []byte(fmt.Sprintf("value %d", i))
+		keys = append(keys, key)
+	}
+	defer store.DeleteMany(ctx, keys)
+
+	require.NoError(t, store.StoreMany(ctx, items, time.Minute))
+	for _, key := range []string{"batch:0", "batch:999", "batch:1499"} {
+		value, found, err := repo.Get(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, found, key)
+		assert.Equal(t, string(items[key]), string(value))
+	}
+
+	require.NoError(t, store.DeleteMany(ctx, keys[:1000]))
+	_, found, _ := repo.Get(ctx, "batch:999")
+	assert.False(t, found)
+	_, found, _ = repo.Get(ctx, "batch:1000")
+	assert.True(t, found)
+
+	require.NoError(t, store.StoreMany(ctx, nil, time.Minute))
+	require.NoError(t, store.DeleteMany(ctx, nil))
+}
+
+func TestMemoryCache_BatchStore(t *testing.T) {
+	cache := NewMemoryCache()
+	testBatchStore(t, cache, cache)
+}
+
+// testGetMany runs the GetMany behaviour shared by every backend.
+func testGetMany(t *testing.T, repo CacheRepo) {
+	ctx := context.Background()
+	defer repo.Delete(ctx, "many:1")
+	defer repo.Delete(ctx, "many:2")
+
+	require.NoError(t, repo.Store(ctx, "many:1", []byte("one"), time.Minute))
+	require.NoError(t, repo.Store(ctx, "many:2", []byte("two"), time.Minute))
+
+	results, err := GetMany(ctx, repo, []string{"many:2", "many:missing", "many:1"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Value: []byte("two"), Found: true},
+		{},
+		{Value: []byte("one"), Found: true},
+	}, results)
+
+	results, err = GetMany(ctx, repo, nil)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMemoryCache_GetMany(t *testing.T) {
+	cache := NewMemoryCache()
+	testGetMany(t, cache)
+
+	// Expired keys are missing, like with Get
+	require.NoError(t, cache.Store(context.Background(), "expired", []byte("value"), -time.Second))
+	results, err := cache.GetMany(context.Background(), []string{"expired"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{}}, results)
+}
+
+func TestGetMany_Fallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	mockRepo.EXPECT().ValuesByKeys(ctx, []string{"a", "b"}).Return([]interface{}{"redis string", nil}, nil)
+	results, err := GetMany(ctx, mockRepo, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{Value: []byte("redis string"), Found: true}, {}}, results)
+
+	mockRepo.EXPECT().ValuesByKeys(ctx, []string{"a", "b"}).Return([]interface{}{}, nil)
+	_, err = GetMany(ctx, mockRepo, []string{"a", "b"})
+	assert.Error(t, err)
+}
+
+func TestNocacheRepo_GetMany(t *testing.T) {
+	ctx := context.Background()
+	repo := NewNocacheRepo()
+
+	results, err := GetMany(ctx, repo, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []Result{{}, {}}, results)
+
+	values, err := repo.ValuesByKeys(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, nil}, values)
+}
+
+func TestBatchError(t *testing.T) {
+	err := error(&BatchError{Errors: map[string]error{
+		"b": ErrNotSupported,
+		"a": errors.New("boom"),
+	}})
+
+	assert.Equal(t, `cache_go: batch failed for 2 keys, first "a": boom`, err.Error())
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	var batchErr *BatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Len(t, batchErr.Errors, 2)
+
+	assert.NoError(t, batchErrors{}.err())
+}
+
+// getManyOnlyCache fails ValuesByKeys, so only its GetMany can read batches.
+type getManyOnlyCache struct {
+	*MemoryCache
+}
+
+func (g getManyOnlyCache) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	return nil, errors.New("ValuesByKeys called")
+}
+
+func TestGetMany_Decorators(t *testing.T) {
+	compressing, err := NewCompressingRepo(getManyOnlyCache{NewMemoryCache()}, CompressionOptions{MinSize: 1})
+	require.NoError(t, err)
+	encrypting, err := NewEncryptingRepo(NewRetryingRepo(compressing, RetryOptions{}), EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID: "v1",
+	})
+	require.NoError(t, err)
+	testGetMany(t, Namespaced(NewSafeKeyRepo(encrypting), "tenant:"))
+}
@@ -2,6 +2,8 @@ package cache_go
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"time"
 )
 
@@ -18,7 +20,55 @@ type CacheRepo interface {
 	LTrim(ctx context.Context, key string, start int64, end int64) error
 	LRem(ctx context.Context, key string, count int64, value []byte) error
 	KeysByPattern(ctx context.Context, pattern string) ([]string, error)
+	// ValuesByKeys returns one value per key, nil for missing keys.
+	//
+	// Deprecated: values are strings on Redis and []byte elsewhere. Use the
+	// GetMany function instead.
 	ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error)
 	Close() error
 	Ping(ctx context.Context) error
 }
+
+// ScriptRunner is implemented by backends that can run Lua scripts atomically,
+// such as RedisCache.
+type ScriptRunner interface {
+	RunScript(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// NoExpiration is the TTL reported for keys that never expire.
+const NoExpiration time.Duration = -1
+
+// TTLStore is implemented by backends that can read and change the expiry of
+// a key without rewriting its value. Expire with a non-positive ttl expires
+// the key at once, while GetAndTouch with a non-positive ttl removes the
+// expiry. Each method reports false for missing keys.
+type TTLStore interface {
+	// TTL returns the remaining time to live of key, or NoExpiration.
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Persist(ctx context.Context, key string) (bool, error)
+	GetAndTouch(ctx context.Context, key string, ttl time.Duration) ([]byte, bool, error)
+}
+
+// ConditionalStore is implemented by backends that can write a key depending
+// on its current state, atomically. A non-positive exp means no expiry.
+// Versions are opaque tokens naming a value; ValueVersion computes them.
+type ConditionalStore interface {
+	// StoreIfAbsent writes key only if it doesn't exist, like SET NX.
+	StoreIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error)
+	// StoreIfPresent writes key only if it exists, like SET XX.
+	StoreIfPresent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error)
+	// Swap writes key and returns the value it replaced, like GETSET.
+	Swap(ctx context.Context, key string, value []byte, exp time.Duration) ([]byte, bool, error)
+	// GetWithVersion returns the value of key along with its version.
+	GetWithVersion(ctx context.Context, key string) ([]byte, string, bool, error)
+	// CompareAndSwap writes key only if it still holds the given version.
+	CompareAndSwap(ctx context.Context, key string, version string, value []byte, exp time.Duration) (bool, error)
+}
+
+// ValueVersion returns the version ConditionalStore reports for value: the
+// hex SHA-1 of its bytes, which Redis scripts can compute too.
+func ValueVersion(value []byte) string {
+	sum := sha1.Sum(value)
+	return hex.EncodeToString(sum[:])
+}
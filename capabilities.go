@@ -0,0 +1,63 @@
+package cache_go
+
+import "errors"
+
+// ErrNotSupported is returned, possibly wrapped, by operations a backend or
+// decorator can't perform. Test for it with errors.Is.
+var ErrNotSupported = errors.New("cache_go: operation not supported")
+
+// Capabilities describes what a backend does natively. Features reached
+// through optional interfaces, such as LockStore, ScriptRunner or
+// PatternDeleter, are discovered with a type assertion instead.
+type Capabilities struct {
+	// PatternScan means KeysByPattern lists matching keys instead of failing.
+	PatternScan bool
+	// AtomicCounters means Increment and IncrementWithTTL are atomic for every
+	// client of the backend.
+	AtomicCounters bool
+	// NativeLists means lists are stored as lists: LPush and LRem are atomic and
+	// elements may contain commas.
+	NativeLists bool
+	// TTLIntrospection means the backend can report the remaining TTL of a key.
+	TTLIntrospection bool
+	// Shared means other processes connected to the backend see the same keys.
+	Shared bool
+}
+
+// CapabilityReporter is implemented by repos that describe their capabilities.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// Unwrapper is implemented by decorators wrapping a single CacheRepo.
+type Unwrapper interface {
+	Unwrap() CacheRepo
+}
+
+// CapabilitiesOf reports the capabilities of repo, walking down decorators
+// until one of them, or the backend, is a CapabilityReporter. Unknown repos
+// report no capabilities.
+func CapabilitiesOf(repo CacheRepo) Capabilities {
+	for repo != nil {
+		if r, ok := repo.(CapabilityReporter); ok {
+			return r.Capabilities()
+		}
+		u, ok := repo.(Unwrapper)
+		if !ok {
+			break
+		}
+		repo = u.Unwrap()
+	}
+	return Capabilities{}
+}
+
+// intersect keeps the capabilities both c and other have.
+func (c Capabilities) intersect(other Capabilities) Capabilities {
+	return Capabilities{
+		PatternScan:      c.PatternScan && other.PatternScan,
+		AtomicCounters:   c.AtomicCounters && other.AtomicCounters,
+		NativeLists:      c.NativeLists && other.NativeLists,
+		TTLIntrospection: c.TTLIntrospection && other.TTLIntrospection,
+		Shared:           c.Shared && other.Shared,
+	}
+}
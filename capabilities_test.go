@@ -0,0 +1,35 @@
+package cache_go
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilitiesOf(t *testing.T) {
+	memory := NewMemoryCache()
+	redisCaps := (&RedisCache{}).Capabilities()
+
+	assert.True(t, redisCaps.PatternScan)
+	assert.True(t, redisCaps.NativeLists)
+	assert.False(t, NewMemcacheRepo("localhost:11211").Capabilities().PatternScan)
+	assert.Equal(t, Capabilities{}, CapabilitiesOf(NewNocacheRepo()))
+	_, err := NewNocacheRepo().KeysByPattern(context.Background(), "*")
+	assert.ErrorIs(t, err, ErrNotSupported)
+
+	// Decorators report the capabilities of the repo they wrap
+	compressing, err := NewCompressingRepo(memory, CompressionOptions{})
+	require.NoError(t, err)
+	wrapped := NewRetryingRepo(Namespaced(compressing, "tenant:"), RetryOptions{})
+	assert.Equal(t, memory.Capabilities(), CapabilitiesOf(wrapped))
+
+	// Repos spanning several backends report what all of them can do
+	hedged := NewHedgedRepo(&RedisCache{}, []CacheRepo{memory}, HedgeOptions{})
+	assert.Equal(t, Capabilities{
+		PatternScan:      true,
+		AtomicCounters:   true,
+		TTLIntrospection: true,
+	}, CapabilitiesOf(hedged))
+}
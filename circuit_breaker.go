@@ -0,0 +1,427 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through to the backend.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen short-circuits every call to NocacheRepo behaviour.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe calls through to decide whether to close again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type CircuitBreakerOptions struct {
+	// Window is the number of recent calls the rates are computed over, 20 if zero.
+	Window int
+	// MinCalls is how many calls the window needs before the breaker can trip, 10 if zero.
+	MinCalls int
+	// ErrorRate trips the breaker once this share of calls in the window failed, 0.5 if zero.
+	ErrorRate float64
+	// SlowCallDuration marks calls taking longer as slow. Zero disables latency tracking.
+	SlowCallDuration time.Duration
+	// SlowCallRate trips the breaker once this share of calls in the window was slow, 0.5 if zero.
+	SlowCallRate float64
+	// OpenTimeout is how long the breaker stays open before probing the backend, 5s if zero.
+	OpenTimeout time.Duration
+	// HalfOpenCalls is the number of probe calls that must all succeed to close the breaker, 1 if zero.
+	HalfOpenCalls int
+	// OnStateChange is called after every state transition.
+	OnStateChange func(from CircuitState, to CircuitState)
+}
+
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// CircuitBreakerRepo is a CacheRepo decorator that stops calling a failing or
+// slow backend. While open, reads miss and writes are dropped exactly like
+// NocacheRepo, so GetFromCache falls back to the source without waiting on
+// connection timeouts. Cancelled calls are not counted, and Ping and Close
+// always reach the backend.
+type CircuitBreakerRepo struct {
+	repo     CacheRepo
+	fallback NocacheRepo
+	opts     CircuitBreakerOptions
+	now      func() time.Time
+
+	mu             sync.Mutex
+	state          CircuitState
+	outcomes       []callOutcome // ring buffer of the last opts.Window calls
+	next           int
+	filled         int
+	openedAt       time.Time
+	probes         int
+	probeSuccesses int
+}
+
+func NewCircuitBreakerRepo(repo CacheRepo, opts CircuitBreakerOptions) *CircuitBreakerRepo {
+	if opts.Window <= 0 {
+		opts.Window = 20
+	}
+	if opts.MinCalls <= 0 {
+		opts.MinCalls = 10
+	}
+	if opts.MinCalls > opts.Window {
+		opts.MinCalls = opts.Window
+	}
+	if opts.ErrorRate <= 0 {
+		opts.ErrorRate = 0.5
+	}
+	if opts.SlowCallRate <= 0 {
+		opts.SlowCallRate = 0.5
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 5 * time.Second
+	}
+	if opts.HalfOpenCalls <= 0 {
+		opts.HalfOpenCalls = 1
+	}
+
+	return &CircuitBreakerRepo{
+		repo:     repo,
+		opts:     opts,
+		now:      time.Now,
+		outcomes: make([]callOutcome, opts.Window),
+	}
+}
+
+func (c *CircuitBreakerRepo) State() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// setState changes the state and returns a func notifying OnStateChange,
+// to be called once c.mu is released. The caller must hold c.mu.
+func (c *CircuitBreakerRepo) setState(to CircuitState) func() {
+	from := c.state
+	if from == to {
+		return func() {}
+	}
+
+	c.state = to
+	c.probes = 0
+	c.probeSuccesses = 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = c.now()
+	case CircuitClosed:
+		c.next, c.filled = 0, 0
+	}
+
+	return func() {
+		if c.opts.OnStateChange != nil {
+			c.opts.OnStateChange(from, to)
+		}
+	}
+}
+
+// acquire reports whether a call may reach the backend, and whether it took
+// one of the half-open probe slots.
+func (c *CircuitBreakerRepo) acquire() (allowed bool, probe bool) {
+	c.mu.Lock()
+	notify := func() {}
+	defer func() {
+		c.mu.Unlock()
+		notify()
+	}()
+
+	if c.state == CircuitOpen {
+		if c.now().Sub(c.openedAt) < c.opts.OpenTimeout {
+			return false, false
+		}
+		notify = c.setState(CircuitHalfOpen)
+	}
+
+	if c.state == CircuitHalfOpen {
+		if c.probes >= c.opts.HalfOpenCalls {
+			return false, false
+		}
+		c.probes++
+		return true, true
+	}
+	return true, false
+}
+
+// record counts the outcome of a call admitted by acquire.
+func (c *CircuitBreakerRepo) record(duration time.Duration, err error, probe bool) {
+	c.mu.Lock()
+	notify := func() {}
+	defer func() {
+		c.mu.Unlock()
+		notify()
+	}()
+
+	if errors.Is(err, context.Canceled) {
+		if probe && c.state == CircuitHalfOpen && c.probes > 0 {
+			// Give the probe slot back.
+			c.probes--
+		}
+		return
+	}
+
+	outcome := callOutcome{
+		failed: err != nil,
+		slow:   c.opts.SlowCallDuration > 0 && duration > c.opts.SlowCallDuration,
+	}
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if !probe {
+			// Admitted while closed, so it says nothing about the recovery.
+			return
+		}
+		if outcome.failed || outcome.slow {
+			notify = c.setState(CircuitOpen)
+			return
+		}
+		c.probeSuccesses++
+		if c.probeSuccesses >= c.opts.HalfOpenCalls {
+			notify = c.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		c.outcomes[c.next] = outcome
+		c.next = (c.next + 1) % len(c.outcomes)
+		if c.filled < len(c.outcomes) {
+			c.filled++
+		}
+		if c.shouldTrip() {
+			notify = c.setState(CircuitOpen)
+		}
+	}
+}
+
+// shouldTrip reports whether the window crossed a threshold. The caller must hold c.mu.
+func (c *CircuitBreakerRepo) shouldTrip() bool {
+	if c.filled < c.opts.MinCalls {
+		return false
+	}
+
+	var failed, slow int
+	for _, o := range c.outcomes[:c.filled] {
+		if o.failed {
+			failed++
+		}
+		if o.slow {
+			slow++
+		}
+	}
+
+	total := float64(c.filled)
+	return float64(failed)/total >= c.opts.ErrorRate ||
+		(c.opts.SlowCallDuration > 0 && float64(slow)/total >= c.opts.SlowCallRate)
+}
+
+// call runs fn unless the breaker is open, and reports whether it ran.
+func (c *CircuitBreakerRepo) call(fn func() error) (bool, error) {
+	allowed, probe := c.acquire()
+	if !allowed {
+		return false, nil
+	}
+
+	start := time.Now()
+	err := fn()
+	c.record(time.Since(start), err, probe)
+	return true, err
+}
+
+func (c *CircuitBreakerRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	ran, err := c.call(func() error {
+		return c.repo.Store(ctx, key, value, exp)
+	})
+	if !ran {
+		return c.fallback.Store(ctx, key, value, exp)
+	}
+	return err
+}
+
+func (c *CircuitBreakerRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	ran, err := c.call(func() error {
+		return c.repo.StoreWithoutTTL(ctx, key, value)
+	})
+	if !ran {
+		return c.fallback.StoreWithoutTTL(ctx, key, value)
+	}
+	return err
+}
+
+func (c *CircuitBreakerRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	ran, err := c.call(func() (err error) {
+		value, found, err = c.repo.Get(ctx, key)
+		return err
+	})
+	if !ran {
+		return c.fallback.Get(ctx, key)
+	}
+	return value, found, err
+}
+
+func (c *CircuitBreakerRepo) Delete(ctx context.Context, key string) error {
+	ran, err := c.call(func() error {
+		return c.repo.Delete(ctx, key)
+	})
+	if !ran {
+		return c.fallback.Delete(ctx, key)
+	}
+	return err
+}
+
+func (c *CircuitBreakerRepo) Increment(ctx context.Context, key string) (int64, error) {
+	var val int64
+	ran, err := c.call(func() (err error) {
+		val, err = c.repo.Increment(ctx, key)
+		return err
+	})
+	if !ran {
+		return c.fallback.Increment(ctx, key)
+	}
+	return val, err
+}
+
+func (c *CircuitBreakerRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	var val int64
+	ran, err := c.call(func() (err error) {
+		val, err = c.repo.IncrementWithTTL(ctx, key, exp)
+		return err
+	})
+	if !ran {
+		return c.fallback.IncrementWithTTL(ctx, key, exp)
+	}
+	return val, err
+}
+
+func (c *CircuitBreakerRepo) LPush(ctx context.Context, key string, value []byte) error {
+	ran, err := c.call(func() error {
+		return c.repo.LPush(ctx, key, value)
+	})
+	if !ran {
+		return c.fallback.LPush(ctx, key, value)
+	}
+	return err
+}
+
+func (c *CircuitBreakerRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	var values []string
+	ran, err := c.call(func() (err error) {
+		values, err = c.repo.LRange(ctx, key, start, end)
+		return err
+	})
+	if !ran {
+		return c.fallback.LRange(ctx, key, start, end)
+	}
+	return values, err
+}
+
+func (c *CircuitBreakerRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	ran, err := c.call(func() error {
+		return c.repo.LTrim(ctx, key, start, end)
+	})
+	if !ran {
+		return c.fallback.LTrim(ctx, key, start, end)
+	}
+	return err
+}
+
+func (c *CircuitBreakerRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	ran, err := c.call(func() error {
+		return c.repo.LRem(ctx, key, count, value)
+	})
+	if !ran {
+		return c.fallback.LRem(ctx, key, count, value)
+	}
+	return err
+}
+
+// KeysByPattern reports no keys while the breaker is open, like reads miss.
+// NocacheRepo isn't used there: it fails, as it can't list keys at all.
+func (c *CircuitBreakerRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	ran, err := c.call(func() (err error) {
+		keys, err = c.repo.KeysByPattern(ctx, pattern)
+		return err
+	})
+	if !ran {
+		return []string{}, nil
+	}
+	return keys, err
+}
+
+// ScanKeys runs every page through the breaker. While it is open the scan
+// ends, as if no more keys matched.
+func (c *CircuitBreakerRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return wrapPages(ScanKeys(ctx, c.repo, pattern, batchSize), func(ctx context.Context, cursor uint64, page scanPageFunc) ([]string, uint64, error) {
+		var (
+			keys []string
+			next uint64
+		)
+		ran, err := c.call(func() (err error) {
+			keys, next, err = page(ctx, cursor)
+			return err
+		})
+		if !ran {
+			return nil, 0, nil
+		}
+		return keys, next, err
+	})
+}
+
+func (c *CircuitBreakerRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	var values []interface{}
+	ran, err := c.call(func() (err error) {
+		values, err = c.repo.ValuesByKeys(ctx, keys)
+		return err
+	})
+	if !ran {
+		return c.fallback.ValuesByKeys(ctx, keys)
+	}
+	return values, err
+}
+
+func (c *CircuitBreakerRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	var results []Result
+	ran, err := c.call(func() (err error) {
+		results, err = GetMany(ctx, c.repo, keys)
+		return err
+	})
+	if !ran {
+		return GetMany(ctx, c.fallback, keys)
+	}
+	return results, err
+}
+
+func (c *CircuitBreakerRepo) Unwrap() CacheRepo {
+	return c.repo
+}
+
+func (c *CircuitBreakerRepo) Close() error {
+	return c.repo.Close()
+}
+
+func (c *CircuitBreakerRepo) Ping(ctx context.Context) error {
+	return c.repo.Ping(ctx)
+}
@@ -0,0 +1,177 @@
+package cache_go
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type stateChange struct {
+	from, to CircuitState
+}
+
+func TestCircuitBreakerRepo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+
+	var changes []stateChange
+	now := time.Unix(1700000000, 0)
+	repo := NewCircuitBreakerRepo(mockRepo, CircuitBreakerOptions{
+		Window:      4,
+		MinCalls:    4,
+		ErrorRate:   0.5,
+		OpenTimeout: time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			changes = append(changes, stateChange{from, to})
+		},
+	})
+	repo.now = func() time.Time { return now }
+
+	// Two failures out of four calls trip the breaker
+	mockRepo.EXPECT().Get(ctx, "key").Return([]byte("value"), true, nil).Times(2)
+	mockRepo.EXPECT().Get(ctx, "key").Return(nil, false, io.EOF).Times(2)
+	for i := 0; i < 4; i++ {
+		_, _, _ = repo.Get(ctx, "key")
+	}
+	assert.Equal(t, CircuitOpen, repo.State())
+
+	// While open, the backend isn't called: reads miss and writes are dropped
+	value, found, err := repo.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, value)
+	assert.NoError(t, repo.Store(ctx, "key", []byte("value"), time.Minute))
+
+	// A failed probe opens the breaker again
+	now = now.Add(time.Minute)
+	mockRepo.EXPECT().Get(ctx, "key").Return(nil, false, io.EOF)
+	_, _, err = repo.Get(ctx, "key")
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, CircuitOpen, repo.State())
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	mockRepo.EXPECT().Get(ctx, "key").Return([]byte("value"), true, nil)
+	value, found, err = repo.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", string(value))
+	assert.Equal(t, CircuitClosed, repo.State())
+
+	assert.Equal(t, []stateChange{
+		{CircuitClosed, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitOpen},
+		{CircuitOpen, CircuitHalfOpen},
+		{CircuitHalfOpen, CircuitClosed},
+	}, changes)
+}
+
+func TestCircuitBreakerRepo_CallsAdmittedWhileClosed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Unix(1700000000, 0)
+	repo := NewCircuitBreakerRepo(mocks.NewMockCacheRepo(ctrl), CircuitBreakerOptions{
+		Window:      1,
+		MinCalls:    1,
+		OpenTimeout: time.Minute,
+	})
+	repo.now = func() time.Time { return now }
+
+	// A call is admitted while closed, then another one trips the breaker
+	allowed, probe := repo.acquire()
+	assert.True(t, allowed)
+	assert.False(t, probe)
+	_, tripping := repo.acquire()
+	repo.record(0, io.EOF, tripping)
+	assert.Equal(t, CircuitOpen, repo.State())
+
+	now = now.Add(time.Minute)
+	allowed, probe = repo.acquire()
+	assert.True(t, allowed)
+	assert.True(t, probe)
+
+	// The first call ending neither frees the probe slot nor closes the breaker
+	repo.record(0, context.Canceled, false)
+	allowed, _ = repo.acquire()
+	assert.False(t, allowed)
+	repo.record(0, nil, false)
+	assert.Equal(t, CircuitHalfOpen, repo.State())
+
+	repo.record(0, nil, true)
+	assert.Equal(t, CircuitClosed, repo.State())
+}
+
+func TestCircuitBreakerRepo_ScanKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	repo := NewCircuitBreakerRepo(mockRepo, CircuitBreakerOptions{Window: 2, MinCalls: 2, OpenTimeout: time.Minute})
+
+	// Failed scans trip the breaker
+	mockRepo.EXPECT().KeysByPattern(ctx, "a:*").Return(nil, io.EOF).Times(2)
+	for i := 0; i < 2; i++ {
+		it := ScanKeys(ctx, repo, "a:*", 0)
+		assert.False(t, it.Next())
+		assert.Equal(t, io.EOF, it.Err())
+	}
+	assert.Equal(t, CircuitOpen, repo.State())
+
+	// While open, scans end without reaching the backend
+	it := ScanKeys(ctx, repo, "a:*", 0)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+	keys, err := repo.KeysByPattern(ctx, "a:*")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestCircuitBreakerRepo_SlowCalls(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	repo := NewCircuitBreakerRepo(mockRepo, CircuitBreakerOptions{
+		Window:           2,
+		MinCalls:         2,
+		SlowCallDuration: time.Millisecond,
+		SlowCallRate:     1,
+	})
+
+	mockRepo.EXPECT().Delete(ctx, "key").DoAndReturn(func(ctx context.Context, key string) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}).Times(2)
+	assert.NoError(t, repo.Delete(ctx, "key"))
+	assert.Equal(t, CircuitClosed, repo.State())
+	assert.NoError(t, repo.Delete(ctx, "key"))
+	assert.Equal(t, CircuitOpen, repo.State())
+}
+
+func TestCircuitBreakerRepo_IgnoresCancellation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	repo := NewCircuitBreakerRepo(mockRepo, CircuitBreakerOptions{Window: 2, MinCalls: 2})
+
+	mockRepo.EXPECT().Get(ctx, "key").Return(nil, false, context.Canceled).Times(3)
+	for i := 0; i < 3; i++ {
+		_, _, err := repo.Get(ctx, "key")
+		assert.Equal(t, context.Canceled, err)
+	}
+	assert.Equal(t, CircuitClosed, repo.State())
+}
@@ -0,0 +1,349 @@
+package cache_go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+type CompressionAlgorithm byte
+
+const (
+	// CompressionNone marks a value stored uncompressed behind a header.
+	CompressionNone CompressionAlgorithm = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionSnappy
+)
+
+// DefaultCompressionMinSize is the smallest value compressed by default.
+const DefaultCompressionMinSize = 1024
+
+// compressionMagic starts the header of every value written by
+// CompressingRepo. The byte following it names the algorithm.
+var compressionMagic = []byte("\x00cz")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec returns process-wide zstd coders; EncodeAll and DecodeAll are
+// safe for concurrent use.
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+type CompressionOptions struct {
+	// Algorithm used for new values, CompressionGzip if zero.
+	Algorithm CompressionAlgorithm
+	// MinSize is the smallest value worth compressing, DefaultCompressionMinSize if zero.
+	MinSize int
+}
+
+// CompressionStats accumulates what a CompressingRepo did since it was created.
+type CompressionStats struct {
+	// Compressed counts stored values that were compressed.
+	Compressed int64
+	// BytesIn and BytesOut are the sizes of those values before and after compression.
+	BytesIn  int64
+	BytesOut int64
+	// CompressTime is the wall-clock time spent compressing, summed over calls.
+	CompressTime time.Duration
+	// Decompressed counts values read back that had to be decompressed.
+	Decompressed int64
+	// DecompressTime is the wall-clock time spent decompressing, summed over calls.
+	DecompressTime time.Duration
+}
+
+// Ratio is BytesIn / BytesOut, zero before anything was compressed.
+func (s CompressionStats) Ratio() float64 {
+	if s.BytesOut == 0 {
+		return 0
+	}
+	return float64(s.BytesIn) / float64(s.BytesOut)
+}
+
+// CompressingRepo is a CacheRepo decorator compressing stored values above a
+// size threshold. Every value it compresses starts with a small header naming
+// the algorithm, so values written uncompressed by older code, and values
+// written with another algorithm, still read back correctly. List values are
+// passed through untouched.
+type CompressingRepo struct {
+	repo      CacheRepo
+	algorithm CompressionAlgorithm
+	minSize   int
+
+	compressed     atomic.Int64
+	bytesIn        atomic.Int64
+	bytesOut       atomic.Int64
+	compressTime   atomic.Int64
+	decompressed   atomic.Int64
+	decompressTime atomic.Int64
+}
+
+func NewCompressingRepo(repo CacheRepo, opts CompressionOptions) (*CompressingRepo, error) {
+	if opts.Algorithm == CompressionNone {
+		opts.Algorithm = CompressionGzip
+	}
+	if opts.Algorithm > CompressionSnappy {
+		return nil, fmt.Errorf("cache_go: unknown compression algorithm %d", opts.Algorithm)
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultCompressionMinSize
+	}
+
+	return &CompressingRepo{
+		repo:      repo,
+		algorithm: opts.Algorithm,
+		minSize:   opts.MinSize,
+	}, nil
+}
+
+func (c *CompressingRepo) Stats() CompressionStats {
+	return CompressionStats{
+		Compressed:     c.compressed.Load(),
+		BytesIn:        c.bytesIn.Load(),
+		BytesOut:       c.bytesOut.Load(),
+		CompressTime:   time.Duration(c.compressTime.Load()),
+		Decompressed:   c.decompressed.Load(),
+		DecompressTime: time.Duration(c.decompressTime.Load()),
+	}
+}
+
+// encode returns the bytes to store for value.
+func (c *CompressingRepo) encode(value []byte) ([]byte, error) {
+	if len(value) >= c.minSize {
+		start := time.Now()
+		compressed, err := compress(c.algorithm, value)
+		if err != nil {
+			return nil, err
+		}
+		c.compressTime.Add(int64(time.Since(start)))
+
+		// Not worth it for incompressible data.
+		if len(compressed)+len(compressionMagic)+1 < len(value) {
+			c.compressed.Add(1)
+			c.bytesIn.Add(int64(len(value)))
+			c.bytesOut.Add(int64(len(compressed)) + int64(len(compressionMagic)) + 1)
+			return withCompressionHeader(c.algorithm, compressed), nil
+		}
+	}
+
+	// A raw value that happens to start with the magic gets a header too, so
+	// it can't be mistaken for a compressed one.
+	if bytes.HasPrefix(value, compressionMagic) {
+		return withCompressionHeader(CompressionNone, value), nil
+	}
+	return value, nil
+}
+
+// decode returns the original value of stored bytes.
+func (c *CompressingRepo) decode(stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, compressionMagic) || len(stored) <= len(compressionMagic) {
+		return stored, nil
+	}
+
+	algorithm := CompressionAlgorithm(stored[len(compressionMagic)])
+	payload := stored[len(compressionMagic)+1:]
+	if algorithm == CompressionNone {
+		return payload, nil
+	}
+
+	start := time.Now()
+	value, err := decompress(algorithm, payload)
+	if err != nil {
+		return nil, err
+	}
+	c.decompressed.Add(1)
+	c.decompressTime.Add(int64(time.Since(start)))
+	return value, nil
+}
+
+func withCompressionHeader(algorithm CompressionAlgorithm, payload []byte) []byte {
+	stored := make([]byte, 0, len(compressionMagic)+1+len(payload))
+	stored = append(stored, compressionMagic...)
+	stored = append(stored, byte(algorithm))
+	return append(stored, payload...)
+}
+
+func compress(algorithm CompressionAlgorithm, value []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		encoder, _, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(value, nil), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, value), nil
+	}
+	return nil, fmt.Errorf("cache_go: unknown compression algorithm %d", algorithm)
+}
+
+func decompress(algorithm CompressionAlgorithm, payload []byte) ([]byte, error) {
+	switch algorithm {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionZstd:
+		_, decoder, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(payload, nil)
+	case CompressionSnappy:
+		return snappy.Decode(nil, payload)
+	}
+	return nil, fmt.Errorf("cache_go: unknown compression algorithm %d", algorithm)
+}
+
+func (c *CompressingRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	stored, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.repo.Store(ctx, key, stored, exp)
+}
+
+func (c *CompressingRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	stored, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.repo.StoreWithoutTTL(ctx, key, stored)
+}
+
+func (c *CompressingRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	stored, found, err := c.repo.Get(ctx, key)
+	if err != nil || !found {
+		return stored, found, err
+	}
+
+	value, err := c.decode(stored)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *CompressingRepo) Delete(ctx context.Context, key string) error {
+	return c.repo.Delete(ctx, key)
+}
+
+func (c *CompressingRepo) Increment(ctx context.Context, key string) (int64, error) {
+	return c.repo.Increment(ctx, key)
+}
+
+func (c *CompressingRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	return c.repo.IncrementWithTTL(ctx, key, exp)
+}
+
+func (c *CompressingRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return c.repo.LPush(ctx, key, value)
+}
+
+func (c *CompressingRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	return c.repo.LRange(ctx, key, start, end)
+}
+
+func (c *CompressingRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return c.repo.LTrim(ctx, key, start, end)
+}
+
+func (c *CompressingRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return c.repo.LRem(ctx, key, count, value)
+}
+
+func (c *CompressingRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	return c.repo.KeysByPattern(ctx, pattern)
+}
+
+func (c *CompressingRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return ScanKeys(ctx, c.repo, pattern, batchSize)
+}
+
+// ValuesByKeys decodes every value, keeping the element type (string or
+// []byte) returned by the wrapped repo.
+func (c *CompressingRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	values, err := c.repo.ValuesByKeys(ctx, keys)
+	if err != nil {
+		return values, err
+	}
+
+	for i, v := range values {
+		switch stored := v.(type) {
+		case []byte:
+			if values[i], err = c.decode(stored); err != nil {
+				return nil, err
+			}
+		case string:
+			value, err := c.decode([]byte(stored))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = string(value)
+		}
+	}
+	return values, nil
+}
+
+func (c *CompressingRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	results, err := GetMany(ctx, c.repo, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if !results[i].Found {
+			continue
+		}
+		if results[i].Value, err = c.decode(results[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (c *CompressingRepo) Unwrap() CacheRepo {
+	return c.repo
+}
+
+func (c *CompressingRepo) Close() error {
+	return c.repo.Close()
+}
+
+func (c *CompressingRepo) Ping(ctx context.Context) error {
+	return c.repo.Ping(ctx)
+}
@@ -0,0 +1,85 @@
+package cache_go
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressingRepo(t *testing.T) {
+	ctx := context.Background()
+	large := []byte(strings.Repeat(`{"id":1,"name":"compressible"},`, 200))
+
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		inner := NewMemoryCache()
+		repo, err := NewCompressingRepo(inner, CompressionOptions{Algorithm: algorithm})
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Store(ctx, "large", large, time.Minute))
+		require.NoError(t, repo.StoreWithoutTTL(ctx, "small", []byte("tiny")))
+
+		stored, _, _ := inner.Get(ctx, "large")
+		assert.Less(t, len(stored), len(large)/5, "algorithm %d should compress", algorithm)
+		stored, _, _ = inner.Get(ctx, "small")
+		assert.Equal(t, "tiny", string(stored), "small values are stored as is")
+
+		value, found, err := repo.Get(ctx, "large")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, large, value)
+
+		values, err := repo.ValuesByKeys(ctx, []string{"large", "small", "missing"})
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{large, []byte("tiny"), nil}, values)
+
+		stats := repo.Stats()
+		assert.Equal(t, int64(1), stats.Compressed)
+		assert.Equal(t, int64(len(large)), stats.BytesIn)
+		assert.Greater(t, stats.Ratio(), 5.0)
+		assert.Equal(t, int64(2), stats.Decompressed)
+	}
+}
+
+func TestCompressingRepo_MixedAndLegacyValues(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryCache()
+	large := []byte(strings.Repeat("abcdefgh", 500))
+
+	gzipRepo, err := NewCompressingRepo(inner, CompressionOptions{Algorithm: CompressionGzip})
+	require.NoError(t, err)
+	zstdRepo, err := NewCompressingRepo(inner, CompressionOptions{Algorithm: CompressionZstd, MinSize: 16})
+	require.NoError(t, err)
+
+	require.NoError(t, gzipRepo.Store(ctx, "gzip", large, time.Minute))
+	require.NoError(t, inner.Store(ctx, "legacy", large, time.Minute))
+
+	for _, key := range []string{"gzip", "legacy"} {
+		value, found, err := zstdRepo.Get(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, large, value, key)
+	}
+}
+
+func TestCompressingRepo_RawValueLookingCompressed(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewCompressingRepo(NewMemoryCache(), CompressionOptions{})
+	require.NoError(t, err)
+
+	tricky := append(append([]byte{}, compressionMagic...), byte(CompressionGzip), 'x')
+	require.NoError(t, repo.Store(ctx, "tricky", tricky, time.Minute))
+
+	value, _, err := repo.Get(ctx, "tricky")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(tricky, value))
+}
+
+func TestNewCompressingRepo_UnknownAlgorithm(t *testing.T) {
+	_, err := NewCompressingRepo(NewMemoryCache(), CompressionOptions{Algorithm: 42})
+	assert.Error(t, err)
+}
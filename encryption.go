@@ -0,0 +1,311 @@
+package cache_go
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDecrypt is returned for stored values that are not encrypted, were
+// encrypted with an unknown key or were tampered with.
+var ErrDecrypt = errors.New("cache_go: cannot decrypt cached value")
+
+// encryptionMagic starts every value written by EncryptingRepo. It is followed
+// by a version byte, the key ID length and key ID, the nonce and the sealed value.
+var encryptionMagic = []byte("\x00ce")
+
+const encryptionVersion = 1
+
+type EncryptionOptions struct {
+	// Keys maps key IDs to AES-128, AES-192 or AES-256 keys. Keep retired keys
+	// here as long as values encrypted with them may still be cached.
+	Keys map[string][]byte
+	// ActiveKeyID names the key encrypting new values.
+	ActiveKeyID string
+	// KeyHashSecret, when set, replaces every cache key with its HMAC-SHA256
+	// so raw identifiers such as emails never reach the backend.
+	KeyHashSecret []byte
+}
+
+// EncryptingRepo is a CacheRepo decorator encrypting stored values with
+// AES-GCM. Each value is bound to its cache key, so it can't be replayed
+// under another key. Counters are stored in plaintext, and LRem is not
+// available because encrypted list elements never compare equal.
+type EncryptingRepo struct {
+	repo          CacheRepo
+	aeads         map[string]cipher.AEAD
+	activeKeyID   string
+	keyHashSecret []byte
+}
+
+func NewEncryptingRepo(repo CacheRepo, opts EncryptionOptions) (*EncryptingRepo, error) {
+	if _, ok := opts.Keys[opts.ActiveKeyID]; !ok {
+		return nil, fmt.Errorf("cache_go: active key %q not found", opts.ActiveKeyID)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(opts.Keys))
+	for id, key := range opts.Keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("cache_go: key ID %q must be 1 to 255 bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("cache_go: key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("cache_go: key %q: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+
+	return &EncryptingRepo{
+		repo:          repo,
+		aeads:         aeads,
+		activeKeyID:   opts.ActiveKeyID,
+		keyHashSecret: opts.KeyHashSecret,
+	}, nil
+}
+
+// key returns the key stored in the backend for key.
+func (e *EncryptingRepo) key(key string) string {
+	if e.keyHashSecret == nil {
+		return key
+	}
+	mac := hmac.New(sha256.New, e.keyHashSecret)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (e *EncryptingRepo) encrypt(storageKey string, value []byte) ([]byte, error) {
+	aead := e.aeads[e.activeKeyID]
+
+	header := make([]byte, 0, len(encryptionMagic)+2+len(e.activeKeyID)+aead.NonceSize())
+	header = append(header, encryptionMagic...)
+	header = append(header, encryptionVersion, byte(len(e.activeKeyID)))
+	header = append(header, e.activeKeyID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, value, []byte(storageKey)), nil
+}
+
+func (e *EncryptingRepo) decrypt(storageKey string, stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, encryptionMagic) {
+		return nil, ErrDecrypt
+	}
+	rest := stored[len(encryptionMagic):]
+	if len(rest) < 2 || rest[0] != encryptionVersion {
+		return nil, ErrDecrypt
+	}
+
+	idLen := int(rest[1])
+	rest = rest[2:]
+	if len(rest) < idLen {
+		return nil, ErrDecrypt
+	}
+	keyID := string(rest[:idLen])
+	rest = rest[idLen:]
+
+	aead, ok := e.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrDecrypt, keyID)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	value, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(storageKey))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return value, nil
+}
+
+func (e *EncryptingRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	storageKey := e.key(key)
+	sealed, err := e.encrypt(storageKey, value)
+	if err != nil {
+		return err
+	}
+	return e.repo.Store(ctx, storageKey, sealed, exp)
+}
+
+func (e *EncryptingRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	storageKey := e.key(key)
+	sealed, err := e.encrypt(storageKey, value)
+	if err != nil {
+		return err
+	}
+	return e.repo.StoreWithoutTTL(ctx, storageKey, sealed)
+}
+
+func (e *EncryptingRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	storageKey := e.key(key)
+	sealed, found, err := e.repo.Get(ctx, storageKey)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	value, err := e.decrypt(storageKey, sealed)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (e *EncryptingRepo) Delete(ctx context.Context, key string) error {
+	return e.repo.Delete(ctx, e.key(key))
+}
+
+func (e *EncryptingRepo) Increment(ctx context.Context, key string) (int64, error) {
+	return e.repo.Increment(ctx, e.key(key))
+}
+
+func (e *EncryptingRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	return e.repo.IncrementWithTTL(ctx, e.key(key), exp)
+}
+
+// LPush encrypts value and base64-encodes it, since some backends join list
+// elements with commas.
+func (e *EncryptingRepo) LPush(ctx context.Context, key string, value []byte) error {
+	storageKey := e.key(key)
+	sealed, err := e.encrypt(storageKey, value)
+	if err != nil {
+		return err
+	}
+	return e.repo.LPush(ctx, storageKey, []byte(base64.RawStdEncoding.EncodeToString(sealed)))
+}
+
+func (e *EncryptingRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	storageKey := e.key(key)
+	elements, err := e.repo.LRange(ctx, storageKey, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, len(elements))
+	for i, element := range elements {
+		sealed, err := base64.RawStdEncoding.DecodeString(element)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		value, err := e.decrypt(storageKey, sealed)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = string(value)
+	}
+	return values, nil
+}
+
+func (e *EncryptingRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return e.repo.LTrim(ctx, e.key(key), start, end)
+}
+
+func (e *EncryptingRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return fmt.Errorf("LRem on encrypted lists: %w", ErrNotSupported)
+}
+
+// KeysByPattern is not available with key hashing, as hashed keys can't be
+// matched against a pattern.
+func (e *EncryptingRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	if e.keyHashSecret != nil {
+		return nil, fmt.Errorf("KeysByPattern with hashed keys: %w", ErrNotSupported)
+	}
+	return e.repo.KeysByPattern(ctx, pattern)
+}
+
+// ScanKeys is not available with key hashing, like KeysByPattern.
+func (e *EncryptingRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	if e.keyHashSecret != nil {
+		return errKeyIterator(ctx, fmt.Errorf("ScanKeys with hashed keys: %w", ErrNotSupported))
+	}
+	return ScanKeys(ctx, e.repo, pattern, batchSize)
+}
+
+func (e *EncryptingRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	storageKeys := make([]string, len(keys))
+	for i, k := range keys {
+		storageKeys[i] = e.key(k)
+	}
+
+	values, err := e.repo.ValuesByKeys(ctx, storageKeys)
+	if err != nil {
+		return values, err
+	}
+
+	for i, v := range values {
+		switch sealed := v.(type) {
+		case []byte:
+			if values[i], err = e.decrypt(storageKeys[i], sealed); err != nil {
+				return nil, err
+			}
+		case string:
+			value, err := e.decrypt(storageKeys[i], []byte(sealed))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = string(value)
+		}
+	}
+	return values, nil
+}
+
+func (e *EncryptingRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	storageKeys := make([]string, len(keys))
+	for i, k := range keys {
+		storageKeys[i] = e.key(k)
+	}
+
+	results, err := GetMany(ctx, e.repo, storageKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if !results[i].Found {
+			continue
+		}
+		if results[i].Value, err = e.decrypt(storageKeys[i], results[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (e *EncryptingRepo) Unwrap() CacheRepo {
+	return e.repo
+}
+
+// Capabilities reports the wrapped repo's capabilities without native lists,
+// and without pattern scan when keys are hashed.
+func (e *EncryptingRepo) Capabilities() Capabilities {
+	caps := CapabilitiesOf(e.repo)
+	caps.NativeLists = false
+	if e.keyHashSecret != nil {
+		caps.PatternScan = false
+	}
+	return caps
+}
+
+func (e *EncryptingRepo) Close() error {
+	return e.repo.Close()
+}
+
+func (e *EncryptingRepo) Ping(ctx context.Context) error {
+	return e.repo.Ping(ctx)
+}
@@ -0,0 +1,138 @@
+package cache_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKeyV1 = bytes.Repeat([]byte{1}, 32)
+	testKeyV2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestEncryptingRepo(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryCache()
+	repo, err := NewEncryptingRepo(inner, EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID: "v1",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Store(ctx, "user:1", []byte("jane@example.com"), time.Minute))
+
+	stored, _, _ := inner.Get(ctx, "user:1")
+	assert.NotContains(t, string(stored), "jane@example.com")
+
+	value, found, err := repo.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "jane@example.com", string(value))
+
+	values, err := repo.ValuesByKeys(ctx, []string{"user:1", "user:2"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("jane@example.com"), nil}, values)
+
+	// A ciphertext copied under another key doesn't decrypt
+	require.NoError(t, inner.Store(ctx, "user:2", stored, time.Minute))
+	_, _, err = repo.Get(ctx, "user:2")
+	assert.True(t, errors.Is(err, ErrDecrypt))
+
+	// Plaintext values are rejected
+	require.NoError(t, inner.Store(ctx, "user:3", []byte("plain"), time.Minute))
+	_, _, err = repo.Get(ctx, "user:3")
+	assert.True(t, errors.Is(err, ErrDecrypt))
+}
+
+func TestEncryptingRepo_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryCache()
+
+	before, err := NewEncryptingRepo(inner, EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID: "v1",
+	})
+	require.NoError(t, err)
+	require.NoError(t, before.Store(ctx, "old", []byte("old value"), time.Minute))
+
+	after, err := NewEncryptingRepo(inner, EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1, "v2": testKeyV2},
+		ActiveKeyID: "v2",
+	})
+	require.NoError(t, err)
+	require.NoError(t, after.Store(ctx, "new", []byte("new value"), time.Minute))
+
+	value, _, err := after.Get(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "old value", string(value))
+
+	_, _, err = before.Get(ctx, "new")
+	assert.ErrorContains(t, err, `unknown key "v2"`)
+}
+
+func TestEncryptingRepo_KeyHashing(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryCache()
+	repo, err := NewEncryptingRepo(inner, EncryptionOptions{
+		Keys:          map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID:   "v1",
+		KeyHashSecret: []byte("secret"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Store(ctx, "user:jane@example.com", []byte("profile"), time.Minute))
+
+	keys, err := inner.KeysByPattern(ctx, "*")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotContains(t, keys[0], "jane")
+
+	value, found, err := repo.Get(ctx, "user:jane@example.com")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "profile", string(value))
+
+	_, err = repo.KeysByPattern(ctx, "user:*")
+	assert.ErrorIs(t, err, ErrNotSupported)
+	it := ScanKeys(ctx, repo, "user:*", 0)
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), ErrNotSupported)
+	assert.False(t, CapabilitiesOf(repo).PatternScan)
+}
+
+func TestEncryptingRepo_Lists(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewEncryptingRepo(NewMemoryCache(), EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID: "v1",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.LPush(ctx, "list", []byte("a,b")))
+	require.NoError(t, repo.LPush(ctx, "list", []byte("c")))
+
+	values, err := repo.LRange(ctx, "list", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a,b"}, values)
+	assert.ErrorIs(t, repo.LRem(ctx, "list", 0, []byte("c")), ErrNotSupported)
+}
+
+func TestNewEncryptingRepo_InvalidOptions(t *testing.T) {
+	_, err := NewEncryptingRepo(NewMemoryCache(), EncryptionOptions{
+		Keys:        map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID: "v2",
+	})
+	assert.Error(t, err)
+
+	_, err = NewEncryptingRepo(NewMemoryCache(), EncryptionOptions{
+		Keys:        map[string][]byte{"v1": []byte("short")},
+		ActiveKeyID: "v1",
+	})
+	assert.Error(t, err)
+}
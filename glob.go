@@ -0,0 +1,85 @@
+package cache_go
+
+// MatchPattern reports whether key matches the glob pattern the way Redis
+// KEYS and SCAN do: "*" matches any run of bytes including "/", "?" any
+// single byte, "[abc]", "[^a]" and "[a-z]" a byte in or out of a set, and
+// "\" escapes the next byte. Like Redis it never fails: malformed patterns
+// are read as literally as possible.
+func MatchPattern(pattern, key string) bool {
+	p, k := 0, 0
+	// Position in pattern after the last "*", and in key where it resumes.
+	star, resume := -1, 0
+	for k < len(key) {
+		if p < len(pattern) && pattern[p] == '*' {
+			p++
+			star, resume = p, k
+			continue
+		}
+		if p < len(pattern) {
+			if next, ok := matchByte(pattern, p, key[k]); ok {
+				p, k = next, k+1
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		// Let the last "*" swallow one more byte and retry.
+		resume++
+		p, k = star, resume
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchByte matches c against the single-byte token at pattern[p], and
+// returns the position after the token.
+func matchByte(pattern string, p int, c byte) (int, bool) {
+	switch pattern[p] {
+	case '?':
+		return p + 1, true
+	case '\\':
+		if p+1 < len(pattern) {
+			return p + 2, pattern[p+1] == c
+		}
+		return p + 1, c == '\\'
+	case '[':
+		return matchClass(pattern, p+1, c)
+	}
+	return p + 1, pattern[p] == c
+}
+
+// matchClass matches c against the set starting at pattern[p], just after
+// "[", and returns the position after its "]". An unterminated set ends with
+// the pattern.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			matched = matched || pattern[p+1] == c
+			p += 2
+		case p+2 < len(pattern) && pattern[p+1] == '-':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (lo <= c && c <= hi)
+			p += 3
+		default:
+			matched = matched || pattern[p] == c
+			p++
+		}
+	}
+	if p < len(pattern) {
+		p++ // "]"
+	}
+	return p, matched != negate
+}
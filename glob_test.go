@@ -0,0 +1,82 @@
+package cache_go
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "a/b/c", true},
+		{"user:*", "user:1/profile", true},
+		{"user:*:name", "user:1/2:name", true},
+		{"*:name", "user:1:age", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"**a", "bba", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h[\\]]llo", "h]llo", true},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"\\?", "?", true},
+		{"a\\", "a\\", true},
+		{"[abc", "b", true},
+		{"[abc", "bc", false},
+		{"[]a", "a", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchPattern(tt.pattern, tt.key), "%q ~ %q", tt.pattern, tt.key)
+	}
+}
+
+// testMatchPattern checks that repo's KeysByPattern agrees with
+// MatchPattern, so patterns behave the same in memory as on Redis.
+func testMatchPattern(t *testing.T, repo CacheRepo) {
+	ctx := context.Background()
+	keys := []string{"glob:a/b", "glob:a", "glob:b", "glob:c", "glob:ab", "glob:*", "glob:?", "glob:-"}
+	for _, key := range keys {
+		require.NoError(t, repo.Store(ctx, key, []byte("v"), time.Minute))
+		defer repo.Delete(ctx, key)
+	}
+
+	for _, pattern := range []string{
+		"glob:*", "glob:a*", "glob:?", "glob:[ab]", "glob:[^ab]",
+		"glob:[a-b]", "glob:\\*", "glob:\\?", "glob:a?b",
+	} {
+		var want []string
+		for _, key := range keys {
+			if MatchPattern(pattern, key) {
+				want = append(want, key)
+			}
+		}
+		got, err := repo.KeysByPattern(ctx, pattern)
+		require.NoError(t, err)
+		sort.Strings(want)
+		sort.Strings(got)
+		assert.Equal(t, want, got, pattern)
+	}
+}
+
+func TestMemoryCache_MatchPattern(t *testing.T) {
+	testMatchPattern(t, NewMemoryCache())
+}
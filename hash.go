@@ -0,0 +1,28 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWrongType is returned, possibly wrapped, when an operation meets a key
+// holding another kind of value, like Redis' WRONGTYPE error.
+var ErrWrongType = errors.New("cache_go: key holds the wrong kind of value")
+
+// HashStore is implemented by backends that store a map of fields under a
+// key, like Redis hashes. A positive ttl given to HSet or HIncrBy sets the
+// expiry of the whole hash; otherwise the hash keeps its expiry, none for a
+// new hash. Deleting the last field deletes the hash.
+type HashStore interface {
+	// HSet writes fields into the hash at key and returns how many were new.
+	HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) (int64, error)
+	HGet(ctx context.Context, key string, field string) ([]byte, bool, error)
+	// HGetAll returns every field of the hash at key, none if it is missing.
+	HGetAll(ctx context.Context, key string) (map[string][]byte, error)
+	// HDel removes fields from the hash at key and returns how many existed.
+	HDel(ctx context.Context, key string, fields ...string) (int64, error)
+	// HIncrBy atomically adds incr to the integer in field, 0 if missing, and
+	// returns the result.
+	HIncrBy(ctx context.Context, key string, field string, incr int64, ttl time.Duration) (int64, error)
+}
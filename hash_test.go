@@ -0,0 +1,142 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHashStore runs the HashStore behaviour shared by every backend. When
+// ttls is not nil it also checks the expiry of the hash.
+func testHashStore(t *testing.T, store HashStore, repo CacheRepo, ttls TTLStore) {
+	ctx := context.Background()
+	defer repo.Delete(ctx, "hash:user")
+	defer repo.Delete(ctx, "hash:counters")
+	defer repo.Delete(ctx, "hash:string")
+
+	added, err := store.HSet(ctx, "hash:user", map[string][]byte{"name": []byte("ann"), "city": []byte("oslo")}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	added, err = store.HSet(ctx, "hash:user", map[string][]byte{"name": []byte("bob"), "age": []byte("30")}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+
+	value, found, err := store.HGet(ctx, "hash:user", "name")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "bob", string(value))
+	_, found, err = store.HGet(ctx, "hash:user", "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+	_, found, err = store.HGet(ctx, "hash:missing", "name")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	fields, err := store.HGetAll(ctx, "hash:user")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"name": []byte("bob"), "city": []byte("oslo"), "age": []byte("30")}, fields)
+	fields, err = store.HGetAll(ctx, "hash:missing")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	if ttls != nil {
+		// Writes without a ttl keep the expiry of the hash.
+		ttl, found, err := ttls.TTL(ctx, "hash:user")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.True(t, ttl > 59*time.Minute && ttl <= time.Hour, "ttl %v", ttl)
+	}
+
+	deleted, err := store.HDel(ctx, "hash:user", "city", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = store.HDel(ctx, "hash:user", "name", "age")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	fields, err = store.HGetAll(ctx, "hash:user")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	n, err := store.HIncrBy(ctx, "hash:counters", "views", 5, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, err = store.HIncrBy(ctx, "hash:counters", "views", -2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	value, _, _ = store.HGet(ctx, "hash:counters", "views")
+	assert.Equal(t, "3", string(value))
+
+	_, err = store.HSet(ctx, "hash:counters", map[string][]byte{"label": []byte("x")}, 0)
+	require.NoError(t, err)
+	_, err = store.HIncrBy(ctx, "hash:counters", "label", 1, 0)
+	assert.Error(t, err)
+
+	// Field increments are atomic.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.HIncrBy(ctx, "hash:counters", "hits", 1, 0)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	value, _, _ = store.HGet(ctx, "hash:counters", "hits")
+	assert.Equal(t, "20", string(value))
+
+	require.NoError(t, repo.Store(ctx, "hash:string", []byte("plain"), time.Minute))
+	_, err = store.HSet(ctx, "hash:string", map[string][]byte{"a": []byte("1")}, 0)
+	assert.True(t, errors.Is(err, ErrWrongType), "HSet: %v", err)
+	_, _, err = store.HGet(ctx, "hash:string", "a")
+	assert.True(t, errors.Is(err, ErrWrongType), "HGet: %v", err)
+	_, err = store.HIncrBy(ctx, "hash:string", "a", 1, time.Hour)
+	assert.True(t, errors.Is(err, ErrWrongType), "HIncrBy: %v", err)
+	if ttls != nil {
+		// A failed write leaves the expiry alone.
+		ttl, _, err := ttls.TTL(ctx, "hash:string")
+		require.NoError(t, err)
+		assert.True(t, ttl <= time.Minute, "ttl %v", ttl)
+	}
+
+	added, err = store.HSet(ctx, "hash:user", nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), added)
+}
+
+func TestMemoryCache_HashStore(t *testing.T) {
+	cache := NewMemoryCache()
+	testHashStore(t, cache, cache, cache)
+}
+
+func TestMemoryCache_HashExpiry(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache()
+
+	_, err := cache.HSet(ctx, "hash", map[string][]byte{"a": []byte("1")}, 10*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	fields, err := cache.HGetAll(ctx, "hash")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	// An expired hash starts over, without an expiry.
+	n, err := cache.HIncrBy(ctx, "hash", "a", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	ttl, _, _ := cache.TTL(ctx, "hash")
+	assert.Equal(t, NoExpiration, ttl)
+
+	// Hashes aren't strings.
+	_, _, err = cache.Get(ctx, "hash")
+	assert.ErrorIs(t, err, ErrWrongType)
+	results, err := cache.GetMany(ctx, []string{"hash"})
+	require.NoError(t, err)
+	assert.False(t, results[0].Found)
+}
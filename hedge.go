@@ -0,0 +1,243 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type HedgeWriteMode int
+
+const (
+	// HedgeWritePrimary sends writes to the primary only, e.g. when the
+	// secondaries are read-only replicas of it.
+	HedgeWritePrimary HedgeWriteMode = iota
+	// HedgeWriteAll sends writes to the primary and then to every secondary.
+	HedgeWriteAll
+)
+
+type HedgeOptions struct {
+	// Delay is how long to wait for a reply before asking the next backend, 10ms if zero.
+	Delay time.Duration
+	// Writes selects the backends writes go to.
+	Writes HedgeWriteMode
+}
+
+// HedgedRepo is a CacheRepo sending Get and ValuesByKeys to a primary and,
+// when it doesn't reply within the hedge delay or fails, to the secondaries
+// one after another. The first successful reply wins, a miss included, and
+// the calls still running are cancelled. Every other operation goes to the
+// primary, and writes also to the secondaries with HedgeWriteAll.
+type HedgedRepo struct {
+	backends []CacheRepo // primary first
+	delay    time.Duration
+	writes   HedgeWriteMode
+}
+
+func NewHedgedRepo(primary CacheRepo, secondaries []CacheRepo, opts HedgeOptions) *HedgedRepo {
+	if opts.Delay <= 0 {
+		opts.Delay = 10 * time.Millisecond
+	}
+
+	return &HedgedRepo{
+		backends: append([]CacheRepo{primary}, secondaries...),
+		delay:    opts.Delay,
+		writes:   opts.Writes,
+	}
+}
+
+type hedgeResult[T any] struct {
+	index int
+	value T
+	err   error
+}
+
+// hedge calls fn on the primary, then on the next backend every time the
+// delay passes or a call fails, until one call succeeds. If all of them fail
+// the primary's error is returned.
+func hedge[T any](ctx context.Context, backends []CacheRepo, delay time.Duration, fn func(ctx context.Context, repo CacheRepo) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so the losing calls never block once we've returned.
+	results := make(chan hedgeResult[T], len(backends))
+	launched := 0
+	launch := func() {
+		index := launched
+		launched++
+		go func() {
+			value, err := fn(ctx, backends[index])
+			results <- hedgeResult[T]{index, value, err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	errs := make([]error, len(backends))
+	failed := 0
+	var zero T
+	for {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				return r.value, nil
+			}
+			errs[r.index] = r.err
+			failed++
+			if failed == len(backends) {
+				return zero, errs[0]
+			}
+			if launched < len(backends) {
+				launch()
+				timer.Reset(delay)
+			}
+		case <-timer.C:
+			if launched < len(backends) {
+				launch()
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// write calls fn on the backends writes go to, primary first, and joins
+// their errors.
+func (h *HedgedRepo) write(fn func(primary bool, repo CacheRepo) error) error {
+	if h.writes != HedgeWriteAll {
+		return fn(true, h.backends[0])
+	}
+
+	var errs []error
+	for i, repo := range h.backends {
+		if err := fn(i == 0, repo); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *HedgedRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.Store(ctx, key, value, exp)
+	})
+}
+
+func (h *HedgedRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.StoreWithoutTTL(ctx, key, value)
+	})
+}
+
+func (h *HedgedRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	type found struct {
+		value []byte
+		found bool
+	}
+	r, err := hedge(ctx, h.backends, h.delay, func(ctx context.Context, repo CacheRepo) (found, error) {
+		value, ok, err := repo.Get(ctx, key)
+		return found{value, ok}, err
+	})
+	return r.value, r.found, err
+}
+
+func (h *HedgedRepo) Delete(ctx context.Context, key string) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.Delete(ctx, key)
+	})
+}
+
+// Increment returns the primary's counter value.
+func (h *HedgedRepo) Increment(ctx context.Context, key string) (int64, error) {
+	var val int64
+	err := h.write(func(primary bool, repo CacheRepo) error {
+		v, err := repo.Increment(ctx, key)
+		if primary {
+			val = v
+		}
+		return err
+	})
+	return val, err
+}
+
+// IncrementWithTTL returns the primary's counter value.
+func (h *HedgedRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	var val int64
+	err := h.write(func(primary bool, repo CacheRepo) error {
+		v, err := repo.IncrementWithTTL(ctx, key, exp)
+		if primary {
+			val = v
+		}
+		return err
+	})
+	return val, err
+}
+
+func (h *HedgedRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.LPush(ctx, key, value)
+	})
+}
+
+func (h *HedgedRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	return h.backends[0].LRange(ctx, key, start, end)
+}
+
+func (h *HedgedRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.LTrim(ctx, key, start, end)
+	})
+}
+
+func (h *HedgedRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return h.write(func(_ bool, repo CacheRepo) error {
+		return repo.LRem(ctx, key, count, value)
+	})
+}
+
+func (h *HedgedRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	return h.backends[0].KeysByPattern(ctx, pattern)
+}
+
+func (h *HedgedRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return ScanKeys(ctx, h.backends[0], pattern, batchSize)
+}
+
+func (h *HedgedRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	return hedge(ctx, h.backends, h.delay, func(ctx context.Context, repo CacheRepo) ([]interface{}, error) {
+		return repo.ValuesByKeys(ctx, keys)
+	})
+}
+
+func (h *HedgedRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	return hedge(ctx, h.backends, h.delay, func(ctx context.Context, repo CacheRepo) ([]Result, error) {
+		return GetMany(ctx, repo, keys)
+	})
+}
+
+// Capabilities reports what every backend can do.
+func (h *HedgedRepo) Capabilities() Capabilities {
+	caps := CapabilitiesOf(h.backends[0])
+	for _, repo := range h.backends[1:] {
+		caps = caps.intersect(CapabilitiesOf(repo))
+	}
+	return caps
+}
+
+// Close closes every backend.
+func (h *HedgedRepo) Close() error {
+	var errs []error
+	for _, repo := range h.backends {
+		if err := repo.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *HedgedRepo) Ping(ctx context.Context) error {
+	return h.backends[0].Ping(ctx)
+}
@@ -0,0 +1,89 @@
+package cache_go
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgedRepo_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	primary := mocks.NewMockCacheRepo(ctrl)
+	secondary := NewMemoryCache()
+	require.NoError(t, secondary.Store(ctx, "key", []byte("from secondary"), time.Minute))
+	repo := NewHedgedRepo(primary, []CacheRepo{secondary}, HedgeOptions{Delay: time.Millisecond})
+
+	t.Run("Fast primary wins", func(t *testing.T) {
+		primary.EXPECT().Get(gomock.Any(), "key").Return([]byte("from primary"), true, nil)
+		value, found, err := repo.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "from primary", string(value))
+	})
+
+	t.Run("Slow primary is hedged and cancelled", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		primary.EXPECT().Get(gomock.Any(), "key").DoAndReturn(func(ctx context.Context, key string) ([]byte, bool, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, false, ctx.Err()
+		})
+		value, found, err := repo.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "from secondary", string(value))
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("primary call not cancelled")
+		}
+	})
+
+	t.Run("Failing primary is hedged immediately", func(t *testing.T) {
+		slow := NewHedgedRepo(primary, []CacheRepo{secondary}, HedgeOptions{Delay: time.Hour})
+		primary.EXPECT().Get(gomock.Any(), "key").Return(nil, false, io.EOF)
+		value, _, err := slow.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "from secondary", string(value))
+	})
+
+	t.Run("Primary error when every backend fails", func(t *testing.T) {
+		failing := mocks.NewMockCacheRepo(ctrl)
+		failing.EXPECT().ValuesByKeys(gomock.Any(), []string{"key"}).Return(nil, io.ErrUnexpectedEOF)
+		primary.EXPECT().ValuesByKeys(gomock.Any(), []string{"key"}).Return(nil, io.EOF)
+		_, err := NewHedgedRepo(primary, []CacheRepo{failing}, HedgeOptions{}).ValuesByKeys(ctx, []string{"key"})
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestHedgedRepo_Writes(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewMemoryCache(), NewMemoryCache()
+
+	repo := NewHedgedRepo(primary, []CacheRepo{secondary}, HedgeOptions{})
+	require.NoError(t, repo.Store(ctx, "only-primary", []byte("value"), time.Minute))
+	_, found, _ := secondary.Get(ctx, "only-primary")
+	assert.False(t, found)
+
+	repo = NewHedgedRepo(primary, []CacheRepo{secondary}, HedgeOptions{Writes: HedgeWriteAll})
+	require.NoError(t, repo.Store(ctx, "everywhere", []byte("value"), time.Minute))
+	_, found, _ = primary.Get(ctx, "everywhere")
+	assert.True(t, found)
+	_, found, _ = secondary.Get(ctx, "everywhere")
+	assert.True(t, found)
+
+	require.NoError(t, primary.Store(ctx, "counter", []byte("41"), time.Minute))
+	val, err := repo.Increment(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), val)
+}
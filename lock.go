@@ -0,0 +1,191 @@
+package cache_go
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"time"
+)
+
+var (
+	// ErrLockNotAcquired is returned when the lock is held by someone else.
+	ErrLockNotAcquired = errors.New("cache_go: lock not acquired")
+	// ErrLockNotHeld is returned when releasing or extending a lock that
+	// expired or was taken over by another owner.
+	ErrLockNotHeld = errors.New("cache_go: lock not held")
+)
+
+// LockStore is implemented by backends that can hold locks owned by a token.
+// Each method must check the token and update the key atomically.
+type LockStore interface {
+	AcquireLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, key string, token string) (bool, error)
+	ExtendLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error)
+}
+
+// Locker hands out mutually exclusive, expiring locks stored in a cache backend.
+type Locker struct {
+	store LockStore
+}
+
+func NewLocker(repo CacheRepo) (*Locker, error) {
+	store, ok := repo.(LockStore)
+	if !ok {
+		return nil, fmt.Errorf("%T locks: %w", repo, ErrNotSupported)
+	}
+
+	return &Locker{
+		store: store,
+	}, nil
+}
+
+// Acquire takes the lock on key for ttl, or returns ErrLockNotAcquired when
+// it is already held.
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := l.store.AcquireLock(ctx, key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	return &Lock{
+		store: l.store,
+		key:   key,
+		token: token,
+	}, nil
+}
+
+// LockRetryOptions controls AcquireWithRetry. Zero values mean unlimited
+// attempts, a 10ms minimum and a 1s maximum backoff.
+type LockRetryOptions struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// AcquireWithRetry keeps trying to take the lock with exponential backoff and
+// jitter until it succeeds, the attempts run out or ctx is done.
+func (l *Locker) AcquireWithRetry(ctx context.Context, key string, ttl time.Duration, opts LockRetryOptions) (*Lock, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 10 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		lock, err := l.Acquire(ctx, key, ttl)
+		if err != ErrLockNotAcquired {
+			return lock, err
+		}
+		if opts.MaxAttempts > 0 && attempt+1 >= opts.MaxAttempts {
+			return nil, err
+		}
+
+		backoff := float64(opts.MinBackoff) * math.Pow(2, float64(attempt))
+		if backoff > float64(opts.MaxBackoff) {
+			backoff = float64(opts.MaxBackoff)
+		}
+		// Full jitter keeps competing pods from retrying in lockstep.
+		wait := time.Duration(mathrand.Int63n(int64(backoff)) + 1)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Lock is a held lock. Release and Extend only succeed while the backend
+// still stores this lock's token.
+type Lock struct {
+	store LockStore
+	key   string
+	token string
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+func (l *Lock) Token() string {
+	return l.token
+}
+
+func (l *Lock) Release(ctx context.Context) error {
+	ok, err := l.store.ReleaseLock(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.store.ExtendLock(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// KeepAlive extends the lock to ttl every ttl/3 until ctx is done. When an
+// extension fails the error is sent on the returned channel and renewal stops.
+// The channel is closed once the renewal goroutine exits.
+func (l *Lock) KeepAlive(ctx context.Context, ttl time.Duration) <-chan error {
+	errs := make(chan error, 1)
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.Extend(ctx, ttl); err != nil {
+					if ctx.Err() == nil {
+						errs <- err
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	return errs
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,128 @@
+package cache_go
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLocker runs the Locker behaviour shared by every LockStore backend.
+func testLocker(t *testing.T, repo CacheRepo) {
+	ctx := context.Background()
+	locker, err := NewLocker(repo)
+	require.NoError(t, err)
+
+	key := "test_lock"
+	_ = repo.Delete(ctx, key)
+
+	lock, err := locker.Acquire(ctx, key, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, key, lock.Key())
+
+	_, err = locker.Acquire(ctx, key, 2*time.Second)
+	assert.Equal(t, ErrLockNotAcquired, err)
+
+	// A stale handle with another token can neither extend nor release
+	stale := &Lock{store: locker.store, key: key, token: "someone-else"}
+	assert.Equal(t, ErrLockNotHeld, stale.Extend(ctx, time.Second))
+	assert.Equal(t, ErrLockNotHeld, stale.Release(ctx))
+
+	assert.NoError(t, lock.Extend(ctx, 2*time.Second))
+	assert.NoError(t, lock.Release(ctx))
+	assert.Equal(t, ErrLockNotHeld, lock.Release(ctx))
+
+	lock, err = locker.Acquire(ctx, key, 2*time.Second)
+	require.NoError(t, err)
+	assert.NoError(t, lock.Release(ctx))
+
+	// Like SET NX in Redis, a lock without a ttl is held until released
+	store := repo.(LockStore)
+	acquired, err := store.AcquireLock(ctx, key, "forever", 0)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = store.AcquireLock(ctx, key, "other", time.Second)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+	released, err := store.ReleaseLock(ctx, key, "forever")
+	require.NoError(t, err)
+	assert.True(t, released)
+}
+
+func TestLocker_MemoryCache(t *testing.T) {
+	testLocker(t, NewMemoryCache())
+}
+
+func TestNewLocker_NotSupported(t *testing.T) {
+	_, err := NewLocker(NewNocacheRepo())
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func TestLocker_Expiry(t *testing.T) {
+	ctx := context.Background()
+	locker, err := NewLocker(NewMemoryCache())
+	require.NoError(t, err)
+
+	lock, err := locker.Acquire(ctx, "expiring", 10*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	other, err := locker.Acquire(ctx, "expiring", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, ErrLockNotHeld, lock.Release(ctx))
+	assert.NoError(t, other.Release(ctx))
+}
+
+func TestLocker_AcquireWithRetry(t *testing.T) {
+	ctx := context.Background()
+	locker, err := NewLocker(NewMemoryCache())
+	require.NoError(t, err)
+
+	held, err := locker.Acquire(ctx, "busy", time.Minute)
+	require.NoError(t, err)
+
+	_, err = locker.AcquireWithRetry(ctx, "busy", time.Minute, LockRetryOptions{MaxAttempts: 3, MinBackoff: time.Millisecond})
+	assert.Equal(t, ErrLockNotAcquired, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = locker.AcquireWithRetry(timeoutCtx, "busy", time.Minute, LockRetryOptions{MinBackoff: time.Millisecond})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = held.Release(ctx)
+	}()
+	lock, err := locker.AcquireWithRetry(ctx, "busy", time.Minute, LockRetryOptions{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	require.NoError(t, err)
+	assert.NoError(t, lock.Release(ctx))
+}
+
+func TestLock_KeepAlive(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCache()
+	locker, err := NewLocker(repo)
+	require.NoError(t, err)
+
+	lock, err := locker.Acquire(ctx, "renewed", 30*time.Millisecond)
+	require.NoError(t, err)
+
+	renewCtx, cancel := context.WithCancel(ctx)
+	errs := lock.KeepAlive(renewCtx, 30*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = locker.Acquire(ctx, "renewed", time.Minute)
+	assert.Equal(t, ErrLockNotAcquired, err, "renewed lock should still be held")
+
+	cancel()
+	for err := range errs {
+		t.Errorf("Unexpected renewal error: %v", err)
+	}
+
+	// Renewal stops with an error once the lock is lost
+	require.NoError(t, lock.Release(ctx))
+	errs = lock.KeepAlive(ctx, 30*time.Millisecond)
+	assert.Equal(t, ErrLockNotHeld, <-errs)
+}
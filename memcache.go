@@ -3,88 +3,191 @@ package cache_go
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 )
 
+// MemcacheRepo stores values larger than its chunk size (DefaultMemcacheChunkSize
+// by default) as several chunk items plus a manifest under the original key.
+//
+// gomemcache calls can't be interrupted, so every method runs the client call
+// in the background and returns ctx.Err() as soon as ctx is done. The
+// abandoned call still finishes within the client Timeout and keeps its pool
+// slot until then.
 type MemcacheRepo struct {
-	client *memcache.Client
+	client    *memcache.Client
+	servers   *memcache.ServerList
+	chunkSize int
+	pool      chan struct{}
+}
+
+// MemcacheConfig configures a MemcacheRepo created by NewMemcacheRepoWithConfig.
+// Zero values fall back to the gomemcache defaults.
+type MemcacheConfig struct {
+	Servers []string
+	// Timeout bounds every network read and write, including dials.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept per server.
+	MaxIdleConns int
+	// PoolSize caps the number of operations in flight at once. Zero means no limit.
+	PoolSize int
+	// ChunkSize is the largest value written as a single item, DefaultMemcacheChunkSize if zero.
+	ChunkSize int
 }
 
 func NewMemcacheRepo(server string) *MemcacheRepo {
+	servers := new(memcache.ServerList)
+	// Like memcache.New, an invalid server surfaces as an error on first use.
+	_ = servers.SetServers(server)
+
 	return &MemcacheRepo{
-		client: memcache.New(server),
+		client:    memcache.NewFromSelector(servers),
+		servers:   servers,
+		chunkSize: DefaultMemcacheChunkSize,
+	}
+}
+
+func NewMemcacheRepoWithConfig(cfg MemcacheConfig) (*MemcacheRepo, error) {
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("memcache: no servers configured")
+	}
+	if cfg.PoolSize < 0 {
+		return nil, fmt.Errorf("memcache: invalid pool size %d", cfg.PoolSize)
+	}
+	if cfg.ChunkSize < 0 || (cfg.ChunkSize > 0 && cfg.ChunkSize <= chunkGenerationLength) {
+		return nil, fmt.Errorf("memcache: invalid chunk size %d", cfg.ChunkSize)
+	}
+
+	servers := new(memcache.ServerList)
+	if err := servers.SetServers(cfg.Servers...); err != nil {
+		return nil, fmt.Errorf("memcache: %w", err)
+	}
+
+	client := memcache.NewFromSelector(servers)
+	client.Timeout = cfg.Timeout
+	client.MaxIdleConns = cfg.MaxIdleConns
+
+	m := &MemcacheRepo{
+		client:    client,
+		servers:   servers,
+		chunkSize: cfg.ChunkSize,
+	}
+	if m.chunkSize == 0 {
+		m.chunkSize = DefaultMemcacheChunkSize
+	}
+	if cfg.PoolSize > 0 {
+		m.pool = make(chan struct{}, cfg.PoolSize)
+	}
+
+	return m, nil
+}
+
+// do runs fn honoring ctx cancellation and the pool size. Results written by
+// fn must only be read when do returns nil.
+func (m *MemcacheRepo) do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if m.pool != nil {
+		select {
+		case m.pool <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	release := func() {
+		if m.pool != nil {
+			<-m.pool
+		}
+	}
+
+	// Nothing can cancel ctx, so skip the goroutine.
+	if ctx.Done() == nil {
+		defer release()
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer release()
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 func (m *MemcacheRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
-	return m.client.Set(&memcache.Item{
-		Key:        key,
-		Value:      value,
-		Expiration: int32(exp.Seconds()),
+	return m.do(ctx, func() error {
+		return m.set(key, value, memcacheExpiration(exp))
 	})
 }
 
 func (m *MemcacheRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
-	return m.client.Set(&memcache.Item{
-		Key:   key,
-		Value: value,
+	return m.do(ctx, func() error {
+		return m.set(key, value, 0)
 	})
 }
 
 func (m *MemcacheRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
-	item, err := m.client.Get(key)
-	if err == memcache.ErrCacheMiss {
-		return nil, false, nil
-	}
+	var (
+		value []byte
+		found bool
+	)
+	err := m.do(ctx, func() (err error) {
+		value, found, err = m.get(key)
+		return err
+	})
 	if err != nil {
 		return nil, false, err
 	}
-	return item.Value, true, nil
+	if found && isMemcacheHash(value) {
+		return nil, false, fmt.Errorf("memcache: get %q: %w", key, ErrWrongType)
+	}
+	return value, found, nil
 }
 
 func (m *MemcacheRepo) Delete(ctx context.Context, key string) error {
-	err := m.client.Delete(key)
-	if err == memcache.ErrCacheMiss {
-		return nil
-	}
-	return err
+	return m.do(ctx, func() error {
+		return m.del(key)
+	})
 }
 
 func (m *MemcacheRepo) Increment(ctx context.Context, key string) (int64, error) {
-	// Initialize with 0 if key doesn't exist
-	_, err := m.client.Get(key)
-	if err == memcache.ErrCacheMiss {
-		err = m.client.Set(&memcache.Item{
-			Key:   key,
-			Value: []byte("0"),
-		})
-		if err != nil {
-			return 0, err
-		}
-	}
-
-	newVal, err := m.client.Increment(key, 1)
+	var val int64
+	err := m.do(ctx, func() (err error) {
+		val, err = m.incr(key, 0)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
-	return int64(newVal), nil
+	return val, nil
 }
 
 func (m *MemcacheRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
-	val, err := m.Increment(ctx, key)
-	if err != nil {
-		return 0, err
-	}
+	var val int64
+	err := m.do(ctx, func() (err error) {
+		expiration := memcacheExpiration(exp)
+		val, err = m.incr(key, expiration)
+		if err != nil {
+			return err
+		}
 
-	// Update TTL
-	err = m.client.Set(&memcache.Item{
-		Key:        key,
-		Value:      []byte(strconv.FormatInt(val, 10)),
-		Expiration: int32(exp.Seconds()),
+		// Touch rather than Set, which would undo concurrent increments.
+		err = m.client.Touch(key, expiration)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		return err
 	})
 	if err != nil {
 		return 0, err
@@ -100,56 +203,29 @@ func (m *MemcacheRepo) LPush(ctx context.Context, key string, value []byte) erro
 }
 
 func (m *MemcacheRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
-	item, err := m.client.Get(key)
-	if err == memcache.ErrCacheMiss {
-		return []string{}, nil
-	}
+	var values []string
+	err := m.do(ctx, func() (err error) {
+		values, err = m.lrange(key, start, end)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	values := strings.Split(string(item.Value), ",")
-	if len(values) == 1 && values[0] == "" {
-		return []string{}, nil
-	}
-
-	// Handle negative indices
-	length := int64(len(values))
-	if start < 0 {
-		start = length + start
-		if start < 0 {
-			start = 0
-		}
-	}
-	if end < 0 {
-		end = length + end
-		if end < 0 {
-			end = 0
-		}
-	}
-	if end >= length {
-		end = length - 1
-	}
-	if start > end {
-		return []string{}, nil
-	}
-
-	return values[start : end+1], nil
+	return values, nil
 }
 
 func (m *MemcacheRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
-	values, err := m.LRange(ctx, key, start, end)
-	if err != nil {
-		return err
-	}
+	return m.do(ctx, func() error {
+		values, err := m.lrange(key, start, end)
+		if err != nil {
+			return err
+		}
 
-	if len(values) == 0 {
-		return m.Delete(ctx, key)
-	}
+		if len(values) == 0 {
+			return m.del(key)
+		}
 
-	return m.client.Set(&memcache.Item{
-		Key:   key,
-		Value: []byte(strings.Join(values, ",")),
+		return m.set(key, []byte(strings.Join(values, ",")), 0)
 	})
 }
 
@@ -194,34 +270,51 @@ func (m *MemcacheRepo) LRem(ctx context.Context, key string, count int64, value
 func (m *MemcacheRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
 	// Memcache doesn't support pattern-based key search
 	// This is a limitation of the memcache protocol
-	return nil, fmt.Errorf("pattern-based key search is not supported in memcache")
+	return nil, fmt.Errorf("memcache: pattern-based key search: %w", ErrNotSupported)
+}
+
+// Capabilities reports no pattern scan, as the memcache protocol can't list
+// keys, and no TTL introspection. Lists are emulated with comma-joined values.
+func (m *MemcacheRepo) Capabilities() Capabilities {
+	return Capabilities{
+		AtomicCounters: true,
+		Shared:         true,
+	}
 }
 
 func (m *MemcacheRepo) Close() error {
-	// Close any open connections
-	return nil
+	// Close idle pooled connections
+	return m.client.Close()
 }
 
 func (m *MemcacheRepo) Ping(ctx context.Context) error {
-	// Try to get a non-existent key to check connection
-	_, err := m.client.Get("__ping__")
-	if err == memcache.ErrCacheMiss {
-		return nil
-	}
-	return err
+	return m.do(ctx, func() error {
+		// Try to get a non-existent key to check connection
+		_, err := m.client.Get("__ping__")
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		return err
+	})
 }
 
 // Helper function for list operations
 func (m *MemcacheRepo) listOp(ctx context.Context, key string, op func([]string) []string) error {
-	item, err := m.client.Get(key)
+	return m.do(ctx, func() error {
+		return m.applyListOp(key, op)
+	})
+}
+
+func (m *MemcacheRepo) applyListOp(key string, op func([]string) []string) error {
+	value, found, err := m.get(key)
 	values := []string{}
 
-	if err != nil && err != memcache.ErrCacheMiss {
+	if err != nil {
 		return err
 	}
 
-	if err == nil && len(item.Value) > 0 {
-		values = strings.Split(string(item.Value), ",")
+	if found && len(value) > 0 {
+		values = strings.Split(string(value), ",")
 		if len(values) == 1 && values[0] == "" {
 			values = []string{}
 		}
@@ -230,28 +323,420 @@ func (m *MemcacheRepo) listOp(ctx context.Context, key string, op func([]string)
 	values = op(values)
 
 	if len(values) == 0 {
-		return m.Delete(ctx, key)
+		return m.del(key)
 	}
 
-	return m.client.Set(&memcache.Item{
-		Key:   key,
-		Value: []byte(strings.Join(values, ",")),
-	})
+	return m.set(key, []byte(strings.Join(values, ",")), 0)
 }
 
 func (m *MemcacheRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
-	var result []interface{}
-	for _, k := range keys {
-		bytes, found, err := m.Get(ctx, k)
+	results, err := m.GetMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return valuesOf(results), nil
+}
+
+// GetMany fetches keys with a single get per server, then reads the chunks
+// of chunked values.
+func (m *MemcacheRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	results := make([]Result, len(keys))
+	err := m.do(ctx, func() error {
+		items, err := m.client.GetMulti(keys)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if found {
-			result = append(result, bytes)
-		} else {
-			result = append(result, nil)
+
+		for i, key := range keys {
+			item, found := items[key]
+			if !found {
+				continue
+			}
+			value, found, err := m.assemble(key, item.Value)
+			if err != nil {
+				return err
+			}
+			// Like MGET, hashes read as missing.
+			results[i] = Result{Value: value, Found: found && !isMemcacheHash(value)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (m *MemcacheRepo) lrange(key string, start int64, end int64) ([]string, error) {
+	value, found, err := m.get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return []string{}, nil
+	}
+
+	values := strings.Split(string(value), ",")
+	if len(values) == 1 && values[0] == "" {
+		return []string{}, nil
+	}
+
+	// Handle negative indices
+	length := int64(len(values))
+	if start < 0 {
+		start = length + start
+		if start < 0 {
+			start = 0
 		}
 	}
+	if end < 0 {
+		end = length + end
+		if end < 0 {
+			end = 0
+		}
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end {
+		return []string{}, nil
+	}
+
+	return values[start : end+1], nil
+}
+
+func (m *MemcacheRepo) del(key string) error {
+	if err := m.deleteChunks(key); err != nil {
+		return err
+	}
+
+	err := m.client.Delete(key)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// incr increments the counter under key, creating it with expiration if
+// missing. The counter is created with add, so concurrent callers never reset
+// each other's increments.
+func (m *MemcacheRepo) incr(key string, expiration int32) (int64, error) {
+	newVal, err := m.client.Increment(key, 1)
+	if err == memcache.ErrCacheMiss {
+		err = m.client.Add(&memcache.Item{
+			Key:        key,
+			Value:      []byte("0"),
+			Expiration: expiration,
+		})
+		// Not stored means another caller created it first.
+		if err != nil && err != memcache.ErrNotStored {
+			return 0, err
+		}
+		newVal, err = m.client.Increment(key, 1)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(newVal), nil
+}
+
+// memcacheMaxRelativeExpiration is the longest expiration memcached takes as
+// a number of seconds; longer ones must be given as a Unix time.
+const memcacheMaxRelativeExpiration = 30 * 24 * time.Hour
+
+// memcacheExpiration converts ttl to a memcache expiration, rounding up so a
+// key never expires earlier than asked. A non-positive ttl means no expiry.
+func memcacheExpiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > memcacheMaxRelativeExpiration {
+		return int32(time.Now().Add(ttl + time.Second - 1).Unix())
+	}
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+func (m *MemcacheRepo) AcquireLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := m.do(ctx, func() error {
+		err := m.client.Add(&memcache.Item{
+			Key:        key,
+			Value:      []byte(token),
+			Expiration: memcacheExpiration(ttl),
+		})
+		if err == memcache.ErrNotStored {
+			return nil
+		}
+		acquired = err == nil
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+// ReleaseLock checks the token and then swaps the lock, with cas, for an item
+// that has already expired. Memcache has no compare-and-delete, and the cas
+// keeps a lock re-acquired in between from being released.
+func (m *MemcacheRepo) ReleaseLock(ctx context.Context, key string, token string) (bool, error) {
+	var released bool
+	err := m.do(ctx, func() error {
+		item, err := m.client.Get(key)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if string(item.Value) != token {
+			return nil
+		}
+
+		// Memcached expires items with a negative expiration at once.
+		item.Expiration = -1
+		err = m.client.CompareAndSwap(item)
+		if err == memcache.ErrCASConflict || err == memcache.ErrNotStored {
+			return nil
+		}
+		released = err == nil
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return released, nil
+}
+
+func (m *MemcacheRepo) ExtendLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	var extended bool
+	err := m.do(ctx, func() error {
+		item, err := m.client.Get(key)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if string(item.Value) != token {
+			return nil
+		}
+
+		item.Expiration = memcacheExpiration(ttl)
+		err = m.client.CompareAndSwap(item)
+		if err == memcache.ErrCASConflict || err == memcache.ErrNotStored {
+			return nil
+		}
+		extended = err == nil
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return extended, nil
+}
+
+// TTL is not supported: the memcache protocol can't report expirations.
+func (m *MemcacheRepo) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	return 0, false, fmt.Errorf("memcache: TTL: %w", ErrNotSupported)
+}
+
+func (m *MemcacheRepo) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	var found bool
+	err := m.do(ctx, func() (err error) {
+		if ttl > 0 {
+			found, err = m.touch(key, memcacheExpiration(ttl))
+			return err
+		}
+
+		_, err = m.client.Get(key)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return m.del(key)
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+func (m *MemcacheRepo) Persist(ctx context.Context, key string) (bool, error) {
+	var found bool
+	err := m.do(ctx, func() (err error) {
+		found, err = m.touch(key, 0)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// GetAndTouch reads key and then touches it, as gomemcache has no gat command.
+func (m *MemcacheRepo) GetAndTouch(ctx context.Context, key string, ttl time.Duration) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := m.do(ctx, func() (err error) {
+		value, found, err = m.get(key)
+		if err != nil || !found {
+			return err
+		}
+
+		found, err = m.touch(key, memcacheExpiration(ttl))
+		return err
+	})
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// StoreIfAbsent uses memcache's add. Like the other conditional writes, it
+// only takes values of up to the chunk size.
+func (m *MemcacheRepo) StoreIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	if len(value) > m.chunkSize {
+		return false, fmt.Errorf("memcache: conditional write of %d bytes: %w", len(value), ErrNotSupported)
+	}
+
+	var stored bool
+	err := m.do(ctx, func() error {
+		err := m.client.Add(&memcache.Item{
+			Key:        key,
+			Value:      value,
+			Expiration: memcacheExpiration(exp),
+		})
+		if err == memcache.ErrNotStored {
+			return nil
+		}
+		stored = err == nil
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+func (m *MemcacheRepo) StoreIfPresent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	var stored bool
+	err := m.do(ctx, func() (err error) {
+		_, _, stored, err = m.update(key, value, memcacheExpiration(exp), func(current []byte, found bool) bool {
+			return found
+		})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return stored, nil
+}
+
+func (m *MemcacheRepo) Swap(ctx context.Context, key string, value []byte, exp time.Duration) ([]byte, bool, error) {
+	var (
+		old   []byte
+		found bool
+	)
+	err := m.do(ctx, func() (err error) {
+		old, found, _, err = m.update(key, value, memcacheExpiration(exp), func(current []byte, found bool) bool {
+			return true
+		})
+		return err
+	})
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return old, true, nil
+}
+
+func (m *MemcacheRepo) GetWithVersion(ctx context.Context, key string) ([]byte, string, bool, error) {
+	value, found, err := m.Get(ctx, key)
+	if err != nil || !found {
+		return nil, "", false, err
+	}
+	return value, ValueVersion(value), true, nil
+}
+
+func (m *MemcacheRepo) CompareAndSwap(ctx context.Context, key string, version string, value []byte, exp time.Duration) (bool, error) {
+	var swapped bool
+	err := m.do(ctx, func() (err error) {
+		_, _, swapped, err = m.update(key, value, memcacheExpiration(exp), func(current []byte, found bool) bool {
+			return found && ValueVersion(current) == version
+		})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (m *MemcacheRepo) StoreMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	expiration := memcacheExpiration(ttl)
+	return m.eachServer(ctx, sortedKeys(items), func(key string) error {
+		return m.set(key, items[key], expiration)
+	})
+}
+
+func (m *MemcacheRepo) DeleteMany(ctx context.Context, keys []string) error {
+	return m.eachServer(ctx, keys, m.del)
+}
+
+// eachServer calls fn for every key, in parallel across the servers the keys
+// map to and one key after another on each server. When ctx ends first, the
+// keys of the servers that had not finished are reported with ctx's error,
+// even though some of them may have been written.
+func (m *MemcacheRepo) eachServer(ctx context.Context, keys []string, fn func(key string) error) error {
+	failed := batchErrors{}
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		addr, err := m.servers.PickServer(key)
+		if err != nil {
+			failed[key] = err
+			continue
+		}
+		groups[addr.String()] = append(groups[addr.String()], key)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []string) {
+			defer wg.Done()
+
+			groupFailed := batchErrors{}
+			err := m.do(ctx, func() error {
+				for _, key := range group {
+					if err := fn(key); err != nil {
+						groupFailed[key] = err
+					}
+				}
+				return nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				for _, key := range group {
+					failed[key] = err
+				}
+				return
+			}
+			for key, err := range groupFailed {
+				failed[key] = err
+			}
+		}(group)
+	}
+	wg.Wait()
 
-	return result, nil
+	return failed.err()
 }
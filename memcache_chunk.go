@@ -0,0 +1,327 @@
+package cache_go
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultMemcacheChunkSize is the largest value MemcacheRepo writes as a single
+// item. Memcached's default item limit is 1 MB including the key and item
+// header, so some room is left for those.
+const DefaultMemcacheChunkSize = 1024*1024 - 4096
+
+// chunkManifestMagic marks a value that is a manifest pointing at chunk items
+// instead of the payload itself.
+var chunkManifestMagic = []byte("\x00cache-go:chunked\x00")
+
+const chunkGenerationLength = 16
+
+type chunkManifest struct {
+	generation string
+	count      int
+	size       int
+}
+
+// chunkKey names chunk i of key, hashed through SafeKey when key is too long
+// to take the suffix.
+func chunkKey(key string, i int) string {
+	return SafeKey(fmt.Sprintf("%s:chunk:%d", key, i))
+}
+
+func newChunkGeneration() (string, error) {
+	b := make([]byte, chunkGenerationLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c chunkManifest) encode() []byte {
+	manifest := fmt.Sprintf("%s:%d:%d", c.generation, c.count, c.size)
+	return append(append([]byte{}, chunkManifestMagic...), manifest...)
+}
+
+func (c chunkManifest) keys(key string) []string {
+	keys := make([]string, c.count)
+	for i := range keys {
+		keys[i] = chunkKey(key, i)
+	}
+	return keys
+}
+
+func parseChunkManifest(value []byte) (chunkManifest, bool) {
+	if !bytes.HasPrefix(value, chunkManifestMagic) {
+		return chunkManifest{}, false
+	}
+
+	parts := strings.Split(string(value[len(chunkManifestMagic):]), ":")
+	if len(parts) != 3 || len(parts[0]) != chunkGenerationLength {
+		return chunkManifest{}, false
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil || count <= 0 {
+		return chunkManifest{}, false
+	}
+	size, err := strconv.Atoi(parts[2])
+	if err != nil || size < 0 {
+		return chunkManifest{}, false
+	}
+
+	return chunkManifest{generation: parts[0], count: count, size: size}, true
+}
+
+// set stores value under key, splitting it into chunks when it does not fit
+// into a single memcache item. Chunks are written before the manifest so a
+// reader never finds a manifest whose chunks were not written yet. Chunks of
+// the previous value that the new one doesn't overwrite are removed
+// afterwards, as they may never expire.
+func (m *MemcacheRepo) set(key string, value []byte, expiration int32) error {
+	previous, chunked, err := m.manifestOf(key)
+	if err != nil {
+		return err
+	}
+
+	count, err := m.write(key, value, expiration)
+	if err != nil || !chunked {
+		return err
+	}
+	for i := count; i < previous.count; i++ {
+		err := m.client.Delete(chunkKey(key, i))
+		if err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
+
+// write stores value under key, as a single item or as chunks and a
+// manifest, and returns the number of chunks written.
+func (m *MemcacheRepo) write(key string, value []byte, expiration int32) (int, error) {
+	if len(value) <= m.chunkSize {
+		return 0, m.client.Set(&memcache.Item{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration,
+		})
+	}
+
+	generation, err := newChunkGeneration()
+	if err != nil {
+		return 0, err
+	}
+
+	payloadSize := m.chunkSize - chunkGenerationLength
+	manifest := chunkManifest{
+		generation: generation,
+		count:      (len(value) + payloadSize - 1) / payloadSize,
+		size:       len(value),
+	}
+
+	for i := 0; i < manifest.count; i++ {
+		start := i * payloadSize
+		end := start + payloadSize
+		if end > len(value) {
+			end = len(value)
+		}
+
+		chunk := make([]byte, 0, chunkGenerationLength+end-start)
+		chunk = append(chunk, generation...)
+		chunk = append(chunk, value[start:end]...)
+		err = m.client.Set(&memcache.Item{
+			Key:        chunkKey(key, i),
+			Value:      chunk,
+			Expiration: expiration,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return manifest.count, m.client.Set(&memcache.Item{
+		Key:        key,
+		Value:      manifest.encode(),
+		Expiration: expiration,
+	})
+}
+
+// get reads the value stored under key, reassembling it from its chunks when
+// needed. A chunked value with a missing chunk, or with a chunk written by a
+// different generation, is reported as a miss.
+func (m *MemcacheRepo) get(key string) ([]byte, bool, error) {
+	item, err := m.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return m.assemble(key, item.Value)
+}
+
+// assemble returns the value stored as key's item, reading its chunks when
+// the item is a manifest.
+func (m *MemcacheRepo) assemble(key string, stored []byte) ([]byte, bool, error) {
+	manifest, chunked := parseChunkManifest(stored)
+	if !chunked {
+		return stored, true, nil
+	}
+
+	keys := manifest.keys(key)
+	items, err := m.client.GetMulti(keys)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value := make([]byte, 0, manifest.size)
+	for _, k := range keys {
+		chunk, found := items[k]
+		if !found || !bytes.HasPrefix(chunk.Value, []byte(manifest.generation)) {
+			return nil, false, nil
+		}
+		value = append(value, chunk.Value[chunkGenerationLength:]...)
+	}
+	if len(value) != manifest.size {
+		return nil, false, nil
+	}
+
+	return value, true, nil
+}
+
+// manifestOf returns the manifest stored under key, if it holds a chunked
+// value.
+func (m *MemcacheRepo) manifestOf(key string) (chunkManifest, bool, error) {
+	item, err := m.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return chunkManifest{}, false, nil
+	}
+	if err != nil {
+		return chunkManifest{}, false, err
+	}
+	manifest, chunked := parseChunkManifest(item.Value)
+	return manifest, chunked, nil
+}
+
+// deleteChunks removes the chunks of the value stored under key, if it is a
+// chunked value.
+func (m *MemcacheRepo) deleteChunks(key string) error {
+	manifest, chunked, err := m.manifestOf(key)
+	if err != nil || !chunked {
+		return err
+	}
+	return m.deleteManifestChunks(key, manifest)
+}
+
+// deleteManifestChunks removes the chunks manifest points at.
+func (m *MemcacheRepo) deleteManifestChunks(key string, manifest chunkManifest) error {
+	for _, k := range manifest.keys(key) {
+		err := m.client.Delete(k)
+		if err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// touch sets a new expiration on key and, for a chunked value, on its chunks
+// first, so the manifest never outlives them. It reports false if key
+// doesn't exist.
+func (m *MemcacheRepo) touch(key string, expiration int32) (bool, error) {
+	item, err := m.client.Get(key)
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if manifest, chunked := parseChunkManifest(item.Value); chunked {
+		for _, k := range manifest.keys(key) {
+			err = m.client.Touch(k, expiration)
+			if err != nil && err != memcache.ErrCacheMiss {
+				return false, err
+			}
+		}
+	}
+
+	err = m.client.Touch(key, expiration)
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// update writes value under key if decide accepts the current value, with
+// found false when key is absent, and returns that value.
+func (m *MemcacheRepo) update(key string, value []byte, expiration int32, decide func(current []byte, found bool) bool) ([]byte, bool, bool, error) {
+	if len(value) > m.chunkSize {
+		return nil, false, false, fmt.Errorf("memcache: conditional write of %d bytes: %w", len(value), ErrNotSupported)
+	}
+
+	return m.modify(key, func(current []byte, found bool) ([]byte, int32, bool, error) {
+		return value, expiration, decide(current, found), nil
+	})
+}
+
+// modify writes under key the value and expiration next derives from the
+// current value, with found false when key is absent, unless next declines,
+// and returns that current value. Memcache's add and cas make the read and
+// the write atomic: a concurrent writer makes it start over, calling next
+// again. Values are written as a single item, as chunks can't be swapped
+// atomically, but a chunked value being replaced has its chunks removed.
+func (m *MemcacheRepo) modify(key string, next func(current []byte, found bool) ([]byte, int32, bool, error)) ([]byte, bool, bool, error) {
+	for {
+		item, err := m.client.Get(key)
+		if err != nil && err != memcache.ErrCacheMiss {
+			return nil, false, false, err
+		}
+
+		var (
+			current []byte
+			found   bool
+		)
+		if item != nil {
+			if current, found, err = m.assemble(key, item.Value); err != nil {
+				return nil, false, false, err
+			}
+		}
+		value, expiration, ok, err := next(current, found)
+		if err != nil {
+			return nil, false, false, err
+		}
+		if !ok {
+			return current, found, false, nil
+		}
+		if len(value) > m.chunkSize {
+			return nil, false, false, fmt.Errorf("memcache: conditional write of %d bytes: %w", len(value), ErrNotSupported)
+		}
+
+		if item == nil {
+			err = m.client.Add(&memcache.Item{Key: key, Value: value, Expiration: expiration})
+			if err == memcache.ErrNotStored {
+				continue
+			}
+		} else {
+			manifest, chunked := parseChunkManifest(item.Value)
+			item.Value = value
+			item.Expiration = expiration
+			err = m.client.CompareAndSwap(item)
+			if err == memcache.ErrCASConflict || err == memcache.ErrNotStored {
+				continue
+			}
+			if err == nil && chunked {
+				err = m.deleteManifestChunks(key, manifest)
+			}
+		}
+		if err != nil {
+			return nil, false, false, err
+		}
+		return current, found, true, nil
+	}
+}
@@ -0,0 +1,189 @@
+package cache_go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// hashMagic marks a value holding a serialized hash.
+var hashMagic = []byte("\x00cache-go:hash\x00")
+
+// memcacheHash is a hash as MemcacheRepo stores it. Memcache can't report the
+// expiry of an item, so the hash carries it to keep it across writes.
+type memcacheHash struct {
+	Fields map[string][]byte `json:"f"`
+	// ExpiresAt is the Unix time the hash expires at, 0 for never.
+	ExpiresAt int64 `json:"e,omitempty"`
+}
+
+func (h memcacheHash) encode() ([]byte, error) {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, hashMagic...), data...), nil
+}
+
+// parseMemcacheHash decodes the hash stored under key, empty if not found.
+func parseMemcacheHash(key string, value []byte, found bool) (memcacheHash, error) {
+	h := memcacheHash{Fields: map[string][]byte{}}
+	if !found {
+		return h, nil
+	}
+	if !isMemcacheHash(value) {
+		return h, fmt.Errorf("memcache: hash %q: %w", key, ErrWrongType)
+	}
+	if err := json.Unmarshal(value[len(hashMagic):], &h); err != nil {
+		return h, fmt.Errorf("memcache: decode hash %q: %w", key, err)
+	}
+	if h.Fields == nil {
+		h.Fields = map[string][]byte{}
+	}
+	return h, nil
+}
+
+// isMemcacheHash reports whether value, as stored, is a serialized hash.
+func isMemcacheHash(value []byte) bool {
+	return bytes.HasPrefix(value, hashMagic)
+}
+
+func (m *MemcacheRepo) getHash(key string) (memcacheHash, error) {
+	value, found, err := m.get(key)
+	if err != nil {
+		return memcacheHash{}, err
+	}
+	return parseMemcacheHash(key, value, found)
+}
+
+// modifyHash applies op to the hash stored under key, with cas so concurrent
+// writers never lose each other's fields; op may run several times. The hash
+// is written back only if op reports a change, with a new expiry if ttl is
+// positive. Memcache can't delete an item conditionally, so a hash left
+// without fields is swapped for one that has already expired.
+func (m *MemcacheRepo) modifyHash(key string, ttl time.Duration, op func(h memcacheHash) (bool, error)) error {
+	_, _, _, err := m.modify(key, func(current []byte, found bool) ([]byte, int32, bool, error) {
+		h, err := parseMemcacheHash(key, current, found)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		changed, err := op(h)
+		if err != nil || !changed {
+			return nil, 0, false, err
+		}
+
+		if ttl > 0 {
+			deadline := time.Now().Add(ttl + time.Second - 1)
+			h.ExpiresAt = deadline.Unix()
+		}
+		value, err := h.encode()
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if len(h.Fields) == 0 {
+			// Memcached expires items with a negative expiration at once.
+			return value, -1, true, nil
+		}
+		// Memcached reads expirations over 30 days as a Unix time.
+		return value, int32(h.ExpiresAt), true, nil
+	})
+	return err
+}
+
+func (m *MemcacheRepo) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	var added int64
+	err := m.do(ctx, func() error {
+		return m.modifyHash(key, ttl, func(h memcacheHash) (bool, error) {
+			added = 0
+			for field, value := range fields {
+				if _, exists := h.Fields[field]; !exists {
+					added++
+				}
+				h.Fields[field] = value
+			}
+			return true, nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+func (m *MemcacheRepo) HGet(ctx context.Context, key string, field string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := m.do(ctx, func() error {
+		h, err := m.getHash(key)
+		value, found = h.Fields[field]
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, found, nil
+}
+
+func (m *MemcacheRepo) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	var fields map[string][]byte
+	err := m.do(ctx, func() error {
+		h, err := m.getHash(key)
+		fields = h.Fields
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (m *MemcacheRepo) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	var deleted int64
+	err := m.do(ctx, func() error {
+		return m.modifyHash(key, 0, func(h memcacheHash) (bool, error) {
+			deleted = 0
+			for _, field := range fields {
+				if _, exists := h.Fields[field]; exists {
+					delete(h.Fields, field)
+					deleted++
+				}
+			}
+			return deleted > 0, nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (m *MemcacheRepo) HIncrBy(ctx context.Context, key string, field string, incr int64, ttl time.Duration) (int64, error) {
+	var value int64
+	err := m.do(ctx, func() error {
+		return m.modifyHash(key, ttl, func(h memcacheHash) (bool, error) {
+			value = 0
+			if current, exists := h.Fields[field]; exists {
+				var err error
+				if value, err = strconv.ParseInt(string(current), 10, 64); err != nil {
+					return false, fmt.Errorf("memcache: hash field %q is not an integer", field)
+				}
+			}
+			value += incr
+			h.Fields[field] = []byte(strconv.FormatInt(value, 10))
+			return true, nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
@@ -2,8 +2,14 @@ package cache_go
 
 import (
 	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
 )
 
 func setupTestMemcache(t *testing.T) *MemcacheRepo {
@@ -80,6 +86,41 @@ func TestMemcacheRepo_Increment(t *testing.T) {
 	_ = cache.Delete(ctx, key)
 }
 
+func TestMemcacheRepo_ConcurrentIncrement(t *testing.T) {
+	cache := setupTestMemcache(t)
+	ctx := context.Background()
+	key := "test_concurrent_counter"
+	_ = cache.Delete(ctx, key)
+	defer cache.Delete(ctx, key)
+
+	// Callers racing to create the counter must not reset each other.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = cache.Increment(ctx, key)
+			} else {
+				_, err = cache.IncrementWithTTL(ctx, key, time.Minute)
+			}
+			if err != nil {
+				t.Errorf("Failed to increment counter: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	value, _, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if string(value) != "20" {
+		t.Errorf("Expected 20 increments, got %s", value)
+	}
+}
+
 func TestMemcacheRepo_ListOperations(t *testing.T) {
 	cache := setupTestMemcache(t)
 	ctx := context.Background()
@@ -143,7 +184,395 @@ func TestMemcacheRepo_KeysByPattern(t *testing.T) {
 	ctx := context.Background()
 
 	_, err := cache.KeysByPattern(ctx, "test:*")
-	if err == nil {
-		t.Error("KeysByPattern should return error for Memcache")
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("KeysByPattern should return ErrNotSupported for Memcache, got %v", err)
+	}
+}
+
+func TestMemcacheRepo_SafeKeys(t *testing.T) {
+	cache := NewSafeKeyRepo(setupTestMemcache(t))
+	ctx := context.Background()
+
+	key := "user profile:" + strings.Repeat("x", MaxMemcacheKeyLength)
+	if err := cache.Store(ctx, key, []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Failed to store value with unsafe key: %v", err)
+	}
+
+	got, exists, err := cache.Get(ctx, key)
+	if err != nil || !exists || string(got) != "value" {
+		t.Errorf("Expected value, got %s (exists=%v, err=%v)", got, exists, err)
+	}
+
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Errorf("Failed to delete unsafe key: %v", err)
+	}
+}
+
+func TestMemcacheRepo_LargeValues(t *testing.T) {
+	cache := setupTestMemcache(t)
+	cache.chunkSize = 1024
+	ctx := context.Background()
+
+	key := "test_large_value"
+	value := []byte(strings.Repeat("0123456789", 1000))
+
+	// Test Store and Get of a chunked value
+	if err := cache.Store(ctx, key, value, time.Minute); err != nil {
+		t.Fatalf("Failed to store large value: %v", err)
+	}
+	got, exists, err := cache.Get(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("Failed to get large value: exists=%v, err=%v", exists, err)
+	}
+	if string(got) != string(value) {
+		t.Errorf("Got %d bytes, want %d bytes", len(got), len(value))
+	}
+
+	// Test a chunk from another write generation is reported as a miss
+	if err := cache.client.Set(&memcache.Item{Key: chunkKey(key, 1), Value: []byte("ffffffffffffffffstale")}); err != nil {
+		t.Fatalf("Failed to overwrite chunk: %v", err)
+	}
+	_, exists, err = cache.Get(ctx, key)
+	if err != nil {
+		t.Errorf("Failed to get value with stale chunk: %v", err)
+	}
+	if exists {
+		t.Error("Value with a chunk from another generation should be a miss")
+	}
+
+	// Test Delete removes every chunk
+	if err := cache.Store(ctx, key, value, time.Minute); err != nil {
+		t.Fatalf("Failed to store large value: %v", err)
+	}
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Errorf("Failed to delete large value: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := cache.client.Get(chunkKey(key, i)); err != memcache.ErrCacheMiss {
+			t.Errorf("Chunk %d should be deleted, got err=%v", i, err)
+		}
+	}
+}
+
+func TestMemcacheRepo_OverwriteChunked(t *testing.T) {
+	cache := setupTestMemcache(t)
+	cache.chunkSize = 64
+	ctx := context.Background()
+	key := "test:overwrite_chunked"
+	defer cache.Delete(ctx, key)
+
+	chunkExists := func(i int) bool {
+		_, err := cache.client.Get(chunkKey(key, i))
+		return err == nil
+	}
+
+	// 10 chunks, then 3: the surplus chunks go
+	if err := cache.StoreWithoutTTL(ctx, key, []byte(strings.Repeat("a", 400))); err != nil {
+		t.Fatalf("Failed to store large value: %v", err)
+	}
+	if err := cache.StoreWithoutTTL(ctx, key, []byte(strings.Repeat("b", 120))); err != nil {
+		t.Fatalf("Failed to store smaller value: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if exists := chunkExists(i); exists != (i < 3) {
+			t.Errorf("Chunk %d exists=%v after shrinking to 3 chunks", i, exists)
+		}
+	}
+	if got, _, _ := cache.Get(ctx, key); string(got) != strings.Repeat("b", 120) {
+		t.Errorf("Got %q after shrinking", got)
+	}
+
+	// A small value leaves no chunk behind
+	if err := cache.StoreWithoutTTL(ctx, key, []byte("small")); err != nil {
+		t.Fatalf("Failed to store small value: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if chunkExists(i) {
+			t.Errorf("Chunk %d should be deleted after storing a small value", i)
+		}
+	}
+}
+
+func TestMemcacheRepo_ChunkedLongKey(t *testing.T) {
+	cache := setupTestMemcache(t)
+	cache.chunkSize = 64
+	ctx := context.Background()
+	key := strings.Repeat("k", MaxMemcacheKeyLength)
+	defer cache.Delete(ctx, key)
+
+	value := []byte(strings.Repeat("0123456789", 20))
+	if err := cache.Store(ctx, key, value, time.Minute); err != nil {
+		t.Fatalf("Failed to store chunked value under a 250 byte key: %v", err)
+	}
+	got, found, err := cache.Get(ctx, key)
+	if err != nil || !found || string(got) != string(value) {
+		t.Errorf("Failed to read chunked value back: found=%v, err=%v", found, err)
+	}
+}
+
+func TestNewMemcacheRepoWithConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     MemcacheConfig
+		wantErr bool
+	}{
+		{name: "valid", cfg: MemcacheConfig{Servers: []string{"localhost:11211"}, PoolSize: 4, Timeout: time.Second}},
+		{name: "no servers", cfg: MemcacheConfig{}, wantErr: true},
+		{name: "negative pool size", cfg: MemcacheConfig{Servers: []string{"localhost:11211"}, PoolSize: -1}, wantErr: true},
+		{name: "chunk size too small", cfg: MemcacheConfig{Servers: []string{"localhost:11211"}, ChunkSize: 8}, wantErr: true},
+		{name: "invalid server", cfg: MemcacheConfig{Servers: []string{"localhost:not-a-port"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewMemcacheRepoWithConfig(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := cache.Close(); err != nil {
+				t.Errorf("Failed to close: %v", err)
+			}
+		})
+	}
+}
+
+func TestMemcacheRepo_Context(t *testing.T) {
+	// A server that accepts connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cache, err := NewMemcacheRepoWithConfig(MemcacheConfig{
+		Servers:  []string{listener.Addr().String()},
+		Timeout:  time.Second,
+		PoolSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create repo: %v", err)
+	}
+	defer cache.Close()
+
+	// Test a cancelled context fails fast
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := cache.Get(ctx, "key"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// Test the deadline is honored although the server never replies
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := cache.Get(ctx, "key"); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Get should return at the deadline, took %v", elapsed)
+	}
+
+	// Test waiting for a pool slot honors the deadline, the abandoned Get still holds it
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cache.Store(ctx, "key", []byte("value"), time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestMemcacheRepo_Locker(t *testing.T) {
+	testLocker(t, setupTestMemcache(t))
+}
+
+func TestMemcacheRepo_TTL(t *testing.T) {
+	cache := setupTestMemcache(t)
+	testTTLStore(t, cache, cache, false)
+
+	if _, _, err := cache.TTL(context.Background(), "key"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestMemcacheRepo_TouchChunks(t *testing.T) {
+	cache := setupTestMemcache(t)
+	cache.chunkSize = 64
+	ctx := context.Background()
+	defer cache.Delete(ctx, "ttl:chunked")
+
+	value := []byte(strings.Repeat("0123456789", 20))
+	if err := cache.Store(ctx, "ttl:chunked", value, time.Minute); err != nil {
+		t.Fatalf("Failed to store chunked value: %v", err)
+	}
+
+	got, found, err := cache.GetAndTouch(ctx, "ttl:chunked", time.Hour)
+	if err != nil || !found || string(got) != string(value) {
+		t.Errorf("GetAndTouch = %v, %v; want the chunked value", found, err)
+	}
+
+	if found, err := cache.Expire(ctx, "ttl:chunked", 0); err != nil || !found {
+		t.Errorf("Expire(0) = %v, %v; want true", found, err)
+	}
+	if _, found, _ := cache.Get(ctx, chunkKey("ttl:chunked", 0)); found {
+		t.Error("Expected chunks to be deleted with the key")
+	}
+}
+
+func TestMemcacheRepo_ConditionalStore(t *testing.T) {
+	cache := setupTestMemcache(t)
+	testConditionalStore(t, cache, cache)
+}
+
+func TestMemcacheRepo_SwapChunked(t *testing.T) {
+	cache := setupTestMemcache(t)
+	cache.chunkSize = 64
+	ctx := context.Background()
+	defer cache.Delete(ctx, "cond:chunked")
+
+	value := []byte(strings.Repeat("0123456789", 20))
+	if err := cache.Store(ctx, "cond:chunked", value, time.Minute); err != nil {
+		t.Fatalf("Failed to store chunked value: %v", err)
+	}
+
+	old, found, err := cache.Swap(ctx, "cond:chunked", []byte("small"), time.Minute)
+	if err != nil || !found || string(old) != string(value) {
+		t.Errorf("Swap = %v, %v; want the chunked value", found, err)
+	}
+	if _, found, _ := cache.Get(ctx, chunkKey("cond:chunked", 0)); found {
+		t.Error("Expected the replaced value's chunks to be deleted")
+	}
+
+	if _, err := cache.StoreIfAbsent(ctx, "cond:big", value, time.Minute); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported for a value above the chunk size, got %v", err)
+	}
+}
+
+func TestMemcacheRepo_BatchStore(t *testing.T) {
+	cache := setupTestMemcache(t)
+	testBatchStore(t, cache, cache)
+}
+
+func TestMemcacheRepo_BatchStorePartialFailure(t *testing.T) {
+	cache := setupTestMemcache(t)
+	ctx := context.Background()
+	defer cache.DeleteMany(ctx, []string{"batch:valid", "batch:invalid"})
+
+	err := cache.StoreMany(ctx, map[string][]byte{
+		"batch:valid":   []byte("value"),
+		"batch:invalid": []byte("value"),
+		"batch invalid": []byte("value"),
+	}, time.Minute)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a *BatchError, got %v", err)
+	}
+	if len(batchErr.Errors) != 1 || !errors.Is(batchErr.Errors["batch invalid"], memcache.ErrMalformedKey) {
+		t.Errorf("Expected only the malformed key to fail, got %v", batchErr.Errors)
+	}
+	if _, found, _ := cache.Get(ctx, "batch:valid"); !found {
+		t.Error("Expected the valid key to be stored")
+	}
+}
+
+func TestMemcacheRepo_GetMany(t *testing.T) {
+	cache := setupTestMemcache(t)
+	testGetMany(t, cache)
+
+	// Chunked values are reassembled
+	cache.chunkSize = 64
+	ctx := context.Background()
+	defer cache.Delete(ctx, "many:chunked")
+	value := []byte(strings.Repeat("0123456789", 20))
+	if err := cache.Store(ctx, "many:chunked", value, time.Minute); err != nil {
+		t.Fatalf("Failed to store chunked value: %v", err)
+	}
+	results, err := cache.GetMany(ctx, []string{"many:chunked"})
+	if err != nil || !results[0].Found || string(results[0].Value) != string(value) {
+		t.Errorf("GetMany = %v, %v; want the chunked value", results, err)
+	}
+}
+
+func TestMemcacheRepo_StoreLongTTL(t *testing.T) {
+	cache := setupTestMemcache(t)
+	ctx := context.Background()
+	defer cache.Delete(ctx, "store:long-ttl")
+
+	// Memcached reads more than 30 days in seconds as a Unix time in the past
+	if err := cache.Store(ctx, "store:long-ttl", []byte("value"), 31*24*time.Hour); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+	if _, found, err := cache.Get(ctx, "store:long-ttl"); err != nil || !found {
+		t.Errorf("Get = %v, %v; want the value stored", found, err)
+	}
+}
+
+func TestMemcacheRepo_HashStore(t *testing.T) {
+	cache := setupTestMemcache(t)
+	testHashStore(t, cache, cache, nil)
+}
+
+func TestMemcacheRepo_HashKeepsExpiry(t *testing.T) {
+	cache := setupTestMemcache(t)
+	ctx := context.Background()
+	defer cache.Delete(ctx, "hash:expiry")
+
+	if _, err := cache.HSet(ctx, "hash:expiry", map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Hour); err != nil {
+		t.Fatalf("Failed to set hash: %v", err)
+	}
+	if _, err := cache.HDel(ctx, "hash:expiry", "a"); err != nil {
+		t.Fatalf("Failed to delete field: %v", err)
+	}
+
+	h, err := cache.getHash("hash:expiry")
+	if err != nil {
+		t.Fatalf("Failed to read hash: %v", err)
+	}
+	if remaining := time.Until(time.Unix(h.ExpiresAt, 0)); remaining < 59*time.Minute || remaining > time.Hour+time.Second {
+		t.Errorf("Expected the hash to keep its expiry, %v left", remaining)
+	}
+	if len(h.Fields) != 1 {
+		t.Errorf("Expected 1 field left, got %v", h.Fields)
+	}
+}
+
+func TestMemcacheRepo_HashDeletedWhenEmpty(t *testing.T) {
+	cache := setupTestMemcache(t)
+	ctx := context.Background()
+	key := "hash:emptied"
+	defer cache.Delete(ctx, key)
+
+	if _, err := cache.HSet(ctx, key, map[string][]byte{"a": []byte("1")}, 0); err != nil {
+		t.Fatalf("Failed to set hash: %v", err)
+	}
+	if _, _, err := cache.Get(ctx, key); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Expected ErrWrongType reading a hash with Get, got %v", err)
+	}
+	if results, _ := cache.GetMany(ctx, []string{key}); results[0].Found {
+		t.Error("Expected GetMany to report a hash as missing")
+	}
+
+	if _, err := cache.HDel(ctx, key, "a"); err != nil {
+		t.Fatalf("Failed to delete field: %v", err)
+	}
+	if _, found, err := cache.Get(ctx, key); found || err != nil {
+		t.Errorf("Expected the emptied hash to be gone, found=%v, err=%v", found, err)
+	}
+	if stored, err := cache.StoreIfAbsent(ctx, key, []byte("v"), time.Minute); !stored || err != nil {
+		t.Errorf("Expected StoreIfAbsent to succeed after the hash was emptied, stored=%v, err=%v", stored, err)
 	}
 }
@@ -3,7 +3,8 @@ package cache_go
 import (
 	"context"
 	"fmt"
-	"path/filepath"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,29 +12,102 @@ import (
 
 type CacheItem struct {
 	value     []byte
+	hash      map[string][]byte   // set for hashes, which have no value
+	set       map[string]struct{} // set for sets, which have no value
+	zset      *sortedSet          // set for sorted sets, which have no value
 	expiresAt time.Time
 }
 
+// isString reports whether item holds a plain value rather than a hash, a
+// set or a sorted set.
+func (i CacheItem) isString() bool {
+	return i.hash == nil && i.set == nil && i.zset == nil
+}
+
+// wrongType reports op met a hash, set or sorted set where it expected a
+// plain value or a list.
+func wrongType(op string, key string) error {
+	return fmt.Errorf("memory: %s %q: %w", op, key, ErrWrongType)
+}
+
 type MemoryCache struct {
-	mu    sync.RWMutex
-	items map[string]CacheItem
+	mu         sync.RWMutex
+	items      map[string]CacheItem
+	maxEntries int
+}
+
+type MemoryCacheConfig struct {
+	// MaxEntries bounds the number of keys held. Zero means no limit.
+	MaxEntries int
 }
 
+// memoryEvictionSamples is the number of keys looked at to pick one to evict.
+const memoryEvictionSamples = 5
+
 func NewMemoryCache() *MemoryCache {
 	return &MemoryCache{
 		items: make(map[string]CacheItem),
 	}
 }
 
+func NewMemoryCacheWithConfig(cfg MemoryCacheConfig) (*MemoryCache, error) {
+	if cfg.MaxEntries < 0 {
+		return nil, fmt.Errorf("memory: invalid max entries %d", cfg.MaxEntries)
+	}
+
+	return &MemoryCache{
+		items:      make(map[string]CacheItem),
+		maxEntries: cfg.MaxEntries,
+	}, nil
+}
+
+// set stores item under key, evicting another key first if the cache is
+// full. The caller must hold m.mu for writing.
+func (m *MemoryCache) set(key string, item CacheItem) {
+	if _, exists := m.items[key]; !exists && m.maxEntries > 0 && len(m.items) >= m.maxEntries {
+		m.evict()
+	}
+	m.items[key] = item
+}
+
+// evict removes one key, approximating Redis' volatile-ttl policy: among a
+// few keys sampled at random it picks an expired one, or else the one
+// closest to expiry, falling back to any sampled key when none has a TTL.
+// The caller must hold m.mu for writing.
+func (m *MemoryCache) evict() {
+	var (
+		victim     string
+		victimItem CacheItem
+		sampled    int
+	)
+	now := time.Now()
+	// Map iteration order is random, which makes the first keys a sample.
+	for key, item := range m.items {
+		if !item.expiresAt.IsZero() && now.After(item.expiresAt) {
+			victim = key
+			break
+		}
+		if sampled == 0 || (!item.expiresAt.IsZero() &&
+			(victimItem.expiresAt.IsZero() || item.expiresAt.Before(victimItem.expiresAt))) {
+			victim, victimItem = key, item
+		}
+		sampled++
+		if sampled == memoryEvictionSamples {
+			break
+		}
+	}
+	delete(m.items, victim)
+}
+
 func (m *MemoryCache) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	expiresAt := time.Now().Add(exp)
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     value,
 		expiresAt: expiresAt,
-	}
+	})
 	return nil
 }
 
@@ -41,10 +115,10 @@ func (m *MemoryCache) StoreWithoutTTL(ctx context.Context, key string, value []b
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     value,
 		expiresAt: time.Time{}, // Zero time means no expiration
-	}
+	})
 	return nil
 }
 
@@ -64,6 +138,9 @@ func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error)
 		m.mu.Unlock()
 		return nil, false, nil
 	}
+	if !item.isString() {
+		return nil, false, wrongType("get", key)
+	}
 
 	return item.value, true, nil
 }
@@ -85,16 +162,18 @@ func (m *MemoryCache) Increment(ctx context.Context, key string) (int64, error)
 		if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
 			// Remove expired key before incrementing
 			delete(m.items, key)
+		} else if !item.isString() {
+			return 0, wrongType("increment", key)
 		} else {
 			val = bytesToInt64(item.value)
 		}
 	}
 
 	val++
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     int64ToBytes(val),
 		expiresAt: time.Time{},
-	}
+	})
 
 	return val, nil
 }
@@ -108,16 +187,18 @@ func (m *MemoryCache) IncrementWithTTL(ctx context.Context, key string, exp time
 		if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
 			// Remove expired key
 			delete(m.items, key)
+		} else if !item.isString() {
+			return 0, wrongType("increment", key)
 		} else {
 			val = bytesToInt64(item.value)
 		}
 	}
 
 	val++
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     int64ToBytes(val),
 		expiresAt: time.Now().Add(exp),
-	}
+	})
 
 	return val, nil
 }
@@ -128,6 +209,9 @@ func (m *MemoryCache) LPush(ctx context.Context, key string, value []byte) error
 
 	var values []string
 	if item, exists := m.items[key]; exists {
+		if !item.isString() {
+			return wrongType("lpush", key)
+		}
 		values = strings.Split(string(item.value), ",")
 		if len(values) == 1 && values[0] == "" {
 			values = []string{}
@@ -135,10 +219,10 @@ func (m *MemoryCache) LPush(ctx context.Context, key string, value []byte) error
 	}
 
 	values = append([]string{string(value)}, values...)
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     []byte(strings.Join(values, ",")),
 		expiresAt: time.Time{},
-	}
+	})
 
 	return nil
 }
@@ -151,6 +235,9 @@ func (m *MemoryCache) LRange(ctx context.Context, key string, start int64, end i
 	if !exists {
 		return []string{}, nil
 	}
+	if !item.isString() {
+		return nil, wrongType("lrange", key)
+	}
 
 	values := strings.Split(string(item.value), ",")
 	if len(values) == 1 && values[0] == "" {
@@ -195,10 +282,10 @@ func (m *MemoryCache) LTrim(ctx context.Context, key string, start int64, end in
 		return nil
 	}
 
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     []byte(strings.Join(values, ",")),
 		expiresAt: time.Time{},
-	}
+	})
 
 	return nil
 }
@@ -211,6 +298,9 @@ func (m *MemoryCache) LRem(ctx context.Context, key string, count int64, value [
 	if !exists {
 		return nil
 	}
+	if !item.isString() {
+		return wrongType("lrem", key)
+	}
 
 	values := strings.Split(string(item.value), ",")
 	if len(values) == 1 && values[0] == "" {
@@ -254,10 +344,10 @@ func (m *MemoryCache) LRem(ctx context.Context, key string, count int64, value [
 		return nil
 	}
 
-	m.items[key] = CacheItem{
+	m.set(key, CacheItem{
 		value:     []byte(strings.Join(result, ",")),
 		expiresAt: time.Time{},
-	}
+	})
 
 	return nil
 }
@@ -268,11 +358,7 @@ func (m *MemoryCache) KeysByPattern(ctx context.Context, pattern string) ([]stri
 
 	var matches []string
 	for key := range m.items {
-		matched, err := filepath.Match(pattern, key)
-		if err != nil {
-			return nil, fmt.Errorf("invalid pattern: %v", err)
-		}
-		if matched {
+		if MatchPattern(pattern, key) {
 			matches = append(matches, key)
 		}
 	}
@@ -280,6 +366,14 @@ func (m *MemoryCache) KeysByPattern(ctx context.Context, pattern string) ([]stri
 	return matches, nil
 }
 
+func (m *MemoryCache) Capabilities() Capabilities {
+	return Capabilities{
+		PatternScan:      true,
+		AtomicCounters:   true,
+		TTLIntrospection: true,
+	}
+}
+
 func (m *MemoryCache) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -305,18 +399,721 @@ func bytesToInt64(b []byte) int64 {
 }
 
 func (m *MemoryCache) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	results, err := m.GetMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return valuesOf(results), nil
+}
+
+func (m *MemoryCache) GetMany(ctx context.Context, keys []string) ([]Result, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	var result []interface{}
-	for _, k := range keys {
-		item, found := m.items[k]
-		if found {
-			result = append(result, item.value)
-		} else {
-			result = append(result, nil)
+	results := make([]Result, len(keys))
+	for i, key := range keys {
+		// Like MGET, hashes and sets of either kind read as missing.
+		if item, ok := m.live(key); ok && item.isString() {
+			results[i] = Result{Value: item.value, Found: true}
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryCache) AcquireLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, exists := m.items[key]; exists {
+		if item.expiresAt.IsZero() || time.Now().Before(item.expiresAt) {
+			return false, nil
+		}
+	}
+
+	// Like SET NX in Redis, a non-positive ttl means the lock never expires.
+	m.set(key, CacheItem{
+		value:     []byte(token),
+		expiresAt: expiresAt(ttl),
+	})
+	return true, nil
+}
+
+func (m *MemoryCache) ReleaseLock(ctx context.Context, key string, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.holdsLock(key, token) {
+		return false, nil
+	}
+
+	delete(m.items, key)
+	return true, nil
+}
+
+func (m *MemoryCache) ExtendLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.holdsLock(key, token) {
+		return false, nil
+	}
+
+	m.set(key, CacheItem{
+		value:     []byte(token),
+		expiresAt: time.Now().Add(ttl),
+	})
+	return true, nil
+}
+
+// holdsLock reports whether key holds an unexpired lock owned by token.
+// The caller must hold m.mu.
+func (m *MemoryCache) holdsLock(key string, token string) bool {
+	item, exists := m.items[key]
+	if !exists || string(item.value) != token {
+		return false
+	}
+	return item.expiresAt.IsZero() || time.Now().Before(item.expiresAt)
+}
+
+func (m *MemoryCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key := range m.items {
+		if MatchPattern(pattern, key) {
+			delete(m.items, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// live returns the unexpired item stored under key. The caller must hold m.mu.
+func (m *MemoryCache) live(key string) (CacheItem, bool) {
+	item, exists := m.items[key]
+	if !exists || (!item.expiresAt.IsZero() && time.Now().After(item.expiresAt)) {
+		return CacheItem{}, false
+	}
+	return item, true
+}
+
+func (m *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.live(key)
+	if !ok {
+		return 0, false, nil
+	}
+	if item.expiresAt.IsZero() {
+		return NoExpiration, true, nil
+	}
+	return time.Until(item.expiresAt), true, nil
+}
+
+func (m *MemoryCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.live(key)
+	if !ok {
+		return false, nil
+	}
+	if ttl <= 0 {
+		delete(m.items, key)
+		return true, nil
+	}
+
+	item.expiresAt = time.Now().Add(ttl)
+	m.items[key] = item
+	return true, nil
+}
+
+func (m *MemoryCache) Persist(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.live(key)
+	if !ok {
+		return false, nil
+	}
+
+	item.expiresAt = time.Time{}
+	m.items[key] = item
+	return true, nil
+}
+
+func (m *MemoryCache) GetAndTouch(ctx context.Context, key string, ttl time.Duration) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.live(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if !item.isString() {
+		return nil, false, wrongType("get and touch", key)
+	}
+
+	item.expiresAt = time.Time{}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	m.items[key] = item
+	return item.value, true, nil
+}
+
+// expiresAt returns the expiry of a key stored now for exp, zero if exp is
+// not positive.
+func expiresAt(exp time.Duration) time.Time {
+	if exp <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(exp)
+}
+
+func (m *MemoryCache) StoreIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.live(key); ok {
+		return false, nil
+	}
+	m.set(key, CacheItem{value: value, expiresAt: expiresAt(exp)})
+	return true, nil
+}
+
+func (m *MemoryCache) StoreIfPresent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.live(key); !ok {
+		return false, nil
+	}
+	m.set(key, CacheItem{value: value, expiresAt: expiresAt(exp)})
+	return true, nil
+}
+
+func (m *MemoryCache) Swap(ctx context.Context, key string, value []byte, exp time.Duration) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, found := m.live(key)
+	if found && !old.isString() {
+		return nil, false, wrongType("swap", key)
+	}
+	m.set(key, CacheItem{value: value, expiresAt: expiresAt(exp)})
+	return old.value, found, nil
+}
+
+func (m *MemoryCache) GetWithVersion(ctx context.Context, key string) ([]byte, string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.live(key)
+	if !ok {
+		return nil, "", false, nil
+	}
+	if !item.isString() {
+		return nil, "", false, wrongType("get with version", key)
+	}
+	return item.value, ValueVersion(item.value), true, nil
+}
+
+func (m *MemoryCache) CompareAndSwap(ctx context.Context, key string, version string, value []byte, exp time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.live(key)
+	if ok && !item.isString() {
+		return false, wrongType("compare and swap", key)
+	}
+	if !ok || ValueVersion(item.value) != version {
+		return false, nil
+	}
+	m.set(key, CacheItem{value: value, expiresAt: expiresAt(exp)})
+	return true, nil
+}
+
+func (m *MemoryCache) StoreMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := expiresAt(ttl)
+	for key, value := range items {
+		m.set(key, CacheItem{value: value, expiresAt: expires})
+	}
+	return nil
+}
+
+func (m *MemoryCache) DeleteMany(ctx context.Context, keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+// ScanKeys pages over a snapshot of the keys taken on the first page, so
+// writers are only blocked while the key names are copied and, briefly, for
+// each page. Keys deleted or expired since the snapshot are skipped.
+func (m *MemoryCache) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	var snapshot []string
+	return newKeyIterator(ctx, 0, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		if cursor == 0 {
+			m.mu.RLock()
+			snapshot = make([]string, 0, len(m.items))
+			for key := range m.items {
+				snapshot = append(snapshot, key)
+			}
+			m.mu.RUnlock()
+		}
+
+		page, next, _ := pageOf(snapshot, cursor, batchSize)
+		var matches []string
+		for _, key := range page {
+			if MatchPattern(pattern, key) {
+				matches = append(matches, key)
+			}
+		}
+
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		keys := matches[:0]
+		for _, key := range matches {
+			if _, ok := m.live(key); ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys, next, nil
+	})
+}
+
+// liveHash returns the unexpired hash stored under key, nil if there is none.
+// The caller must hold m.mu.
+func (m *MemoryCache) liveHash(key string) (CacheItem, error) {
+	item, ok := m.live(key)
+	if !ok {
+		return CacheItem{}, nil
+	}
+	if item.hash == nil {
+		return CacheItem{}, fmt.Errorf("memory: hash %q: %w", key, ErrWrongType)
+	}
+	return item, nil
+}
+
+// setHash stores the hash item under key, with a new expiry if ttl is
+// positive. The caller must hold m.mu for writing.
+func (m *MemoryCache) setHash(key string, item CacheItem, ttl time.Duration) {
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	m.set(key, item)
+}
+
+func (m *MemoryCache) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.liveHash(key)
+	if err != nil {
+		return 0, err
+	}
+	if item.hash == nil {
+		item.hash = make(map[string][]byte, len(fields))
+	}
+
+	var added int64
+	for field, value := range fields {
+		if _, exists := item.hash[field]; !exists {
+			added++
+		}
+		item.hash[field] = value
+	}
+	m.setHash(key, item, ttl)
+	return added, nil
+}
+
+func (m *MemoryCache) HGet(ctx context.Context, key string, field string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, err := m.liveHash(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := item.hash[field]
+	return value, ok, nil
+}
+
+func (m *MemoryCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, err := m.liveHash(key)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string][]byte, len(item.hash))
+	for field, value := range item.hash {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+func (m *MemoryCache) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.liveHash(key)
+	if err != nil || item.hash == nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, field := range fields {
+		if _, exists := item.hash[field]; exists {
+			delete(item.hash, field)
+			deleted++
+		}
+	}
+	if len(item.hash) == 0 {
+		delete(m.items, key)
+	}
+	return deleted, nil
+}
+
+func (m *MemoryCache) HIncrBy(ctx context.Context, key string, field string, incr int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.liveHash(key)
+	if err != nil {
+		return 0, err
+	}
+	if item.hash == nil {
+		item.hash = make(map[string][]byte, 1)
+	}
+
+	var value int64
+	if current, exists := item.hash[field]; exists {
+		if value, err = strconv.ParseInt(string(current), 10, 64); err != nil {
+			return 0, fmt.Errorf("memory: hash field %q is not an integer", field)
+		}
+	}
+	value += incr
+	item.hash[field] = []byte(strconv.FormatInt(value, 10))
+	m.setHash(key, item, ttl)
+	return value, nil
+}
+
+// liveSet returns the unexpired set stored under key, nil if there is none.
+// The caller must hold m.mu.
+func (m *MemoryCache) liveSet(key string) (map[string]struct{}, error) {
+	item, ok := m.live(key)
+	if !ok {
+		return nil, nil
+	}
+	if item.set == nil {
+		return nil, fmt.Errorf("memory: set %q: %w", key, ErrWrongType)
+	}
+	return item.set, nil
+}
+
+func (m *MemoryCache) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set, err := m.liveSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		set = make(map[string]struct{}, len(members))
+		m.set(key, CacheItem{set: set})
+	}
+
+	var added int64
+	for _, member := range members {
+		if _, exists := set[member]; !exists {
+			set[member] = struct{}{}
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (m *MemoryCache) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set, err := m.liveSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, member := range members {
+		if _, exists := set[member]; exists {
+			delete(set, member)
+			removed++
 		}
 	}
+	if len(set) == 0 {
+		delete(m.items, key)
+	}
+	return removed, nil
+}
+
+func (m *MemoryCache) SMembers(ctx context.Context, key string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set, err := m.liveSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(set), nil
+}
+
+func (m *MemoryCache) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set, err := m.liveSet(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := set[member]
+	return ok, nil
+}
+
+func (m *MemoryCache) SCard(ctx context.Context, key string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	return result, nil
+	set, err := m.liveSet(key)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(set)), nil
+}
+
+func (m *MemoryCache) SInter(ctx context.Context, keys ...string) ([]string, error) {
+	return m.combineSets(keys, func(result map[string]struct{}, set map[string]struct{}) {
+		for member := range result {
+			if _, ok := set[member]; !ok {
+				delete(result, member)
+			}
+		}
+	})
+}
+
+func (m *MemoryCache) SUnion(ctx context.Context, keys ...string) ([]string, error) {
+	return m.combineSets(keys, func(result map[string]struct{}, set map[string]struct{}) {
+		for member := range set {
+			result[member] = struct{}{}
+		}
+	})
+}
+
+func (m *MemoryCache) SDiff(ctx context.Context, keys ...string) ([]string, error) {
+	return m.combineSets(keys, func(result map[string]struct{}, set map[string]struct{}) {
+		for member := range set {
+			delete(result, member)
+		}
+	})
+}
+
+// combineSets folds the sets at keys[1:] into a copy of the set at keys[0]
+// with op, under a single lock.
+func (m *MemoryCache) combineSets(keys []string, op func(result map[string]struct{}, set map[string]struct{})) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sets := make([]map[string]struct{}, len(keys))
+	for i, key := range keys {
+		set, err := m.liveSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	if len(sets) == 0 {
+		return []string{}, nil
+	}
+
+	result := make(map[string]struct{}, len(sets[0]))
+	for member := range sets[0] {
+		result[member] = struct{}{}
+	}
+	for _, set := range sets[1:] {
+		op(result, set)
+	}
+	return setMembers(result), nil
+}
+
+func setMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members
+}
+
+// liveSortedSet returns the unexpired sorted set stored under key, nil if
+// there is none. The caller must hold m.mu.
+func (m *MemoryCache) liveSortedSet(key string) (*sortedSet, error) {
+	item, ok := m.live(key)
+	if !ok {
+		return nil, nil
+	}
+	if item.zset == nil {
+		return nil, fmt.Errorf("memory: sorted set %q: %w", key, ErrWrongType)
+	}
+	return item.zset, nil
+}
+
+func (m *MemoryCache) ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error) {
+	for _, z := range members {
+		if math.IsNaN(z.Score) {
+			return 0, fmt.Errorf("memory: score of %q is not a number", z.Member)
+		}
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if zset == nil {
+		zset = newSortedSet()
+		m.set(key, CacheItem{zset: zset})
+	}
+
+	var added int64
+	for _, z := range members {
+		if zset.add(z.Member, z.Score) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (m *MemoryCache) ZIncrBy(ctx context.Context, key string, member string, incr float64) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	score := incr
+	if zset != nil {
+		score += zset.scores[member]
+	}
+	if math.IsNaN(score) {
+		return 0, fmt.Errorf("memory: score of %q would not be a number", member)
+	}
+	if zset == nil {
+		zset = newSortedSet()
+		m.set(key, CacheItem{zset: zset})
+	}
+	zset.add(member, score)
+	return score, nil
+}
+
+func (m *MemoryCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []string{}, nil
+	}
+	return zset.rangeByScore(min, max), nil
+}
+
+func (m *MemoryCache) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) ([]ZMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if zset == nil {
+		return []ZMember{}, nil
+	}
+	return zset.revRange(start, stop), nil
+}
+
+func (m *MemoryCache) ZRank(ctx context.Context, key string, member string) (int64, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	rank, ok := zset.rank(member)
+	return int64(rank), ok, nil
+}
+
+func (m *MemoryCache) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, member := range members {
+		if zset.remove(member) {
+			removed++
+		}
+	}
+	if zset.len() == 0 {
+		delete(m.items, key)
+	}
+	return removed, nil
+}
+
+func (m *MemoryCache) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zset, err := m.liveSortedSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	removed := zset.removeRangeByScore(min, max)
+	if zset.len() == 0 {
+		delete(m.items, key)
+	}
+	return int64(removed), nil
 }
@@ -2,6 +2,7 @@ package cache_go
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -266,9 +267,208 @@ func TestMemoryCache_KeysByPattern(t *testing.T) {
 		t.Errorf("Expected to find 2 keys matching pattern 'test:*', found %d", matchCount)
 	}
 
-	// Test invalid pattern
-	_, err = cache.KeysByPattern(ctx, "[invalid")
-	if err == nil {
-		t.Error("Expected error for invalid pattern")
+	// "*" crosses "/" as in Redis
+	cache.StoreWithoutTTL(ctx, "test:a/b", []byte("value"))
+	keys, _ = cache.KeysByPattern(ctx, "test:*")
+	if len(keys) != 3 {
+		t.Errorf("Expected 'test:*' to match 'test:a/b', got %v", keys)
+	}
+
+	// Unterminated sets are matched leniently as in Redis
+	keys, err = cache.KeysByPattern(ctx, "[o")
+	if err != nil {
+		t.Errorf("Unexpected error for unterminated set: %v", err)
+	}
+}
+
+func TestMemoryCache_MaxEntries(t *testing.T) {
+	cache, err := NewMemoryCacheWithConfig(MemoryCacheConfig{MaxEntries: 3})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	ctx := context.Background()
+
+	cache.Store(ctx, "soon", []byte("value"), time.Second)
+	cache.Store(ctx, "later", []byte("value"), time.Hour)
+	cache.StoreWithoutTTL(ctx, "forever", []byte("value"))
+
+	// Overwriting an existing key doesn't evict
+	cache.Store(ctx, "later", []byte("new value"), time.Hour)
+	if keys, _ := cache.KeysByPattern(ctx, "*"); len(keys) != 3 {
+		t.Errorf("Expected 3 keys, got %v", keys)
+	}
+
+	// The key closest to expiry goes first
+	cache.Store(ctx, "new", []byte("value"), time.Hour)
+	if _, found, _ := cache.Get(ctx, "soon"); found {
+		t.Error("Expected 'soon' to be evicted")
+	}
+	for _, key := range []string{"later", "forever", "new"} {
+		if _, found, _ := cache.Get(ctx, key); !found {
+			t.Errorf("Expected %q to be kept", key)
+		}
+	}
+
+	if _, err := NewMemoryCacheWithConfig(MemoryCacheConfig{MaxEntries: -1}); err == nil {
+		t.Error("Expected error for negative max entries")
+	}
+}
+
+// testTTLStore runs the TTLStore behaviour shared by every backend. Backends
+// that can't report TTLs are only checked through Get.
+func testTTLStore(t *testing.T, store TTLStore, repo CacheRepo, introspect bool) {
+	ctx := context.Background()
+	defer repo.Delete(ctx, "ttl:key")
+
+	if found, err := store.Expire(ctx, "ttl:missing", time.Minute); err != nil || found {
+		t.Errorf("Expire on missing key = %v, %v; want false", found, err)
+	}
+	if found, err := store.Persist(ctx, "ttl:missing"); err != nil || found {
+		t.Errorf("Persist on missing key = %v, %v; want false", found, err)
+	}
+	if _, found, err := store.GetAndTouch(ctx, "ttl:missing", time.Minute); err != nil || found {
+		t.Errorf("GetAndTouch on missing key = %v, %v; want false", found, err)
+	}
+
+	if err := repo.Store(ctx, "ttl:key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Failed to store key: %v", err)
+	}
+
+	if found, err := store.Expire(ctx, "ttl:key", time.Hour); err != nil || !found {
+		t.Errorf("Expire = %v, %v; want true", found, err)
+	}
+	if introspect {
+		ttl, found, err := store.TTL(ctx, "ttl:key")
+		if err != nil || !found || ttl <= time.Minute || ttl > time.Hour {
+			t.Errorf("TTL after Expire = %v, %v, %v; want about an hour", ttl, found, err)
+		}
+	}
+
+	if found, err := store.Persist(ctx, "ttl:key"); err != nil || !found {
+		t.Errorf("Persist = %v, %v; want true", found, err)
+	}
+	if introspect {
+		ttl, found, err := store.TTL(ctx, "ttl:key")
+		if err != nil || !found || ttl != NoExpiration {
+			t.Errorf("TTL after Persist = %v, %v, %v; want NoExpiration", ttl, found, err)
+		}
+	}
+
+	value, found, err := store.GetAndTouch(ctx, "ttl:key", time.Minute)
+	if err != nil || !found || string(value) != "value" {
+		t.Errorf("GetAndTouch = %q, %v, %v; want value", value, found, err)
+	}
+	if introspect {
+		ttl, found, err := store.TTL(ctx, "ttl:key")
+		if err != nil || !found || ttl <= 0 || ttl > time.Minute {
+			t.Errorf("TTL after GetAndTouch = %v, %v, %v; want about a minute", ttl, found, err)
+		}
+	}
+
+	if found, err := store.Expire(ctx, "ttl:key", 0); err != nil || !found {
+		t.Errorf("Expire(0) = %v, %v; want true", found, err)
+	}
+	if _, found, _ := repo.Get(ctx, "ttl:key"); found {
+		t.Error("Expected key to expire at once")
+	}
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	cache := NewMemoryCache()
+	testTTLStore(t, cache, cache, true)
+
+	if _, found, _ := cache.TTL(context.Background(), "missing"); found {
+		t.Error("Expected missing key to have no TTL")
+	}
+}
+
+// testConditionalStore runs the ConditionalStore behaviour shared by every backend.
+func testConditionalStore(t *testing.T, store ConditionalStore, repo CacheRepo) {
+	ctx := context.Background()
+	defer repo.Delete(ctx, "cond:key")
+	repo.Delete(ctx, "cond:key")
+
+	if stored, err := store.StoreIfPresent(ctx, "cond:key", []byte("v0"), time.Minute); err != nil || stored {
+		t.Errorf("StoreIfPresent on missing key = %v, %v; want false", stored, err)
+	}
+	if stored, err := store.StoreIfAbsent(ctx, "cond:key", []byte("v1"), time.Minute); err != nil || !stored {
+		t.Errorf("StoreIfAbsent = %v, %v; want true", stored, err)
+	}
+	if stored, err := store.StoreIfAbsent(ctx, "cond:key", []byte("v2"), time.Minute); err != nil || stored {
+		t.Errorf("StoreIfAbsent on existing key = %v, %v; want false", stored, err)
+	}
+	if stored, err := store.StoreIfPresent(ctx, "cond:key", []byte("v2"), 0); err != nil || !stored {
+		t.Errorf("StoreIfPresent = %v, %v; want true", stored, err)
+	}
+
+	old, found, err := store.Swap(ctx, "cond:key", []byte("v3"), time.Minute)
+	if err != nil || !found || string(old) != "v2" {
+		t.Errorf("Swap = %q, %v, %v; want v2", old, found, err)
+	}
+
+	value, version, found, err := store.GetWithVersion(ctx, "cond:key")
+	if err != nil || !found || string(value) != "v3" || version != ValueVersion([]byte("v3")) {
+		t.Errorf("GetWithVersion = %q, %q, %v, %v; want v3", value, version, found, err)
+	}
+	if swapped, err := store.CompareAndSwap(ctx, "cond:key", version, []byte("v4"), time.Minute); err != nil || !swapped {
+		t.Errorf("CompareAndSwap = %v, %v; want true", swapped, err)
+	}
+	// The version is stale now
+	if swapped, err := store.CompareAndSwap(ctx, "cond:key", version, []byte("v5"), time.Minute); err != nil || swapped {
+		t.Errorf("CompareAndSwap with stale version = %v, %v; want false", swapped, err)
+	}
+	if value, _, _ := repo.Get(ctx, "cond:key"); string(value) != "v4" {
+		t.Errorf("Expected v4, got %q", value)
+	}
+
+	repo.Delete(ctx, "cond:key")
+	if old, found, err := store.Swap(ctx, "cond:key", []byte("v6"), time.Minute); err != nil || found || old != nil {
+		t.Errorf("Swap on missing key = %q, %v, %v; want nothing", old, found, err)
+	}
+	if swapped, err := store.CompareAndSwap(ctx, "missing", version, []byte("v7"), time.Minute); err != nil || swapped {
+		t.Errorf("CompareAndSwap on missing key = %v, %v; want false", swapped, err)
+	}
+}
+
+func TestMemoryCache_ConditionalStore(t *testing.T) {
+	cache := NewMemoryCache()
+	testConditionalStore(t, cache, cache)
+}
+
+func TestMemoryCache_WrongType(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache()
+	cache.HSet(ctx, "hash", map[string][]byte{"a": []byte("1")}, 0)
+	cache.SAdd(ctx, "set", "a")
+	cache.ZAdd(ctx, "zset", ZMember{"a", 1})
+
+	for _, key := range []string{"hash", "set", "zset"} {
+		ops := map[string]error{}
+		_, ops["Increment"] = cache.Increment(ctx, key)
+		_, ops["IncrementWithTTL"] = cache.IncrementWithTTL(ctx, key, time.Minute)
+		ops["LPush"] = cache.LPush(ctx, key, []byte("x"))
+		_, ops["LRange"] = cache.LRange(ctx, key, 0, -1)
+		ops["LTrim"] = cache.LTrim(ctx, key, 0, -1)
+		ops["LRem"] = cache.LRem(ctx, key, 0, []byte("x"))
+		_, _, ops["GetAndTouch"] = cache.GetAndTouch(ctx, key, time.Minute)
+		_, _, ops["Swap"] = cache.Swap(ctx, key, []byte("x"), 0)
+		_, _, _, ops["GetWithVersion"] = cache.GetWithVersion(ctx, key)
+		_, ops["CompareAndSwap"] = cache.CompareAndSwap(ctx, key, ValueVersion(nil), []byte("x"), 0)
+		for op, err := range ops {
+			if !errors.Is(err, ErrWrongType) {
+				t.Errorf("%s on %s: expected ErrWrongType, got %v", op, key, err)
+			}
+		}
+	}
+
+	// The structures are left untouched
+	if fields, _ := cache.HGetAll(ctx, "hash"); string(fields["a"]) != "1" {
+		t.Errorf("Expected the hash to survive, got %v", fields)
+	}
+	if n, _ := cache.SCard(ctx, "set"); n != 1 {
+		t.Errorf("Expected the set to survive, got %d members", n)
+	}
+	if _, found, _ := cache.ZRank(ctx, "zset", "a"); !found {
+		t.Error("Expected the sorted set to survive")
 	}
 }
@@ -0,0 +1,227 @@
+package cache_go
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// MetricsRecorder receives the measurements taken by InstrumentedRepo.
+// Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// ObserveOperation is called once per CacheRepo call.
+	ObserveOperation(backend string, operation string, prefix string, duration time.Duration, err error)
+	// ObserveLookup reports hits and misses of Get and ValuesByKeys calls.
+	ObserveLookup(backend string, operation string, prefix string, hits int, misses int)
+	// ObserveLoader is called for every fnCacheable call made by GetFromCache.
+	ObserveLoader(backend string, prefix string, duration time.Duration, err error)
+}
+
+// LoaderObserver is implemented by decorators that want to know about the
+// fnCacheable calls made by GetFromCache and GetFromCacheWithDynamicTTL.
+type LoaderObserver interface {
+	ObserveLoader(ctx context.Context, key string, duration time.Duration, err error)
+}
+
+// DefaultKeyPrefix returns the part of key before the first ':', which is the
+// prefixKey given to GetFromCache.
+func DefaultKeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+type InstrumentOptions struct {
+	// Backend labels every measurement, e.g. "redis" or "memcache".
+	Backend string
+	// KeyPrefix extracts the prefix label from a key, DefaultKeyPrefix if nil.
+	// Keep its output low-cardinality.
+	KeyPrefix func(key string) string
+}
+
+// InstrumentedRepo is a CacheRepo decorator reporting counts, errors, latency
+// and hits/misses of every call to a MetricsRecorder.
+type InstrumentedRepo struct {
+	repo      CacheRepo
+	recorder  MetricsRecorder
+	backend   string
+	keyPrefix func(key string) string
+}
+
+func NewInstrumentedRepo(repo CacheRepo, recorder MetricsRecorder, opts InstrumentOptions) *InstrumentedRepo {
+	if opts.KeyPrefix == nil {
+		opts.KeyPrefix = DefaultKeyPrefix
+	}
+
+	return &InstrumentedRepo{
+		repo:      repo,
+		recorder:  recorder,
+		backend:   opts.Backend,
+		keyPrefix: opts.KeyPrefix,
+	}
+}
+
+func (r *InstrumentedRepo) observe(operation string, key string, start time.Time, err error) {
+	r.recorder.ObserveOperation(r.backend, operation, r.keyPrefix(key), time.Since(start), err)
+}
+
+func (r *InstrumentedRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	start := time.Now()
+	err := r.repo.Store(ctx, key, value, exp)
+	r.observe("Store", key, start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	start := time.Now()
+	err := r.repo.StoreWithoutTTL(ctx, key, value)
+	r.observe("StoreWithoutTTL", key, start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	start := time.Now()
+	value, found, err := r.repo.Get(ctx, key)
+	r.observe("Get", key, start, err)
+	if err == nil {
+		if found {
+			r.recorder.ObserveLookup(r.backend, "Get", r.keyPrefix(key), 1, 0)
+		} else {
+			r.recorder.ObserveLookup(r.backend, "Get", r.keyPrefix(key), 0, 1)
+		}
+	}
+	return value, found, err
+}
+
+func (r *InstrumentedRepo) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := r.repo.Delete(ctx, key)
+	r.observe("Delete", key, start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) Increment(ctx context.Context, key string) (int64, error) {
+	start := time.Now()
+	val, err := r.repo.Increment(ctx, key)
+	r.observe("Increment", key, start, err)
+	return val, err
+}
+
+func (r *InstrumentedRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	start := time.Now()
+	val, err := r.repo.IncrementWithTTL(ctx, key, exp)
+	r.observe("IncrementWithTTL", key, start, err)
+	return val, err
+}
+
+func (r *InstrumentedRepo) LPush(ctx context.Context, key string, value []byte) error {
+	start := time.Now()
+	err := r.repo.LPush(ctx, key, value)
+	r.observe("LPush", key, start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	began := time.Now()
+	values, err := r.repo.LRange(ctx, key, start, end)
+	r.observe("LRange", key, began, err)
+	return values, err
+}
+
+func (r *InstrumentedRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	began := time.Now()
+	err := r.repo.LTrim(ctx, key, start, end)
+	r.observe("LTrim", key, began, err)
+	return err
+}
+
+func (r *InstrumentedRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	start := time.Now()
+	err := r.repo.LRem(ctx, key, count, value)
+	r.observe("LRem", key, start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	start := time.Now()
+	keys, err := r.repo.KeysByPattern(ctx, pattern)
+	r.observe("KeysByPattern", pattern, start, err)
+	return keys, err
+}
+
+// ScanKeys observes every page as a ScanKeys operation.
+func (r *InstrumentedRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return wrapPages(ScanKeys(ctx, r.repo, pattern, batchSize), func(ctx context.Context, cursor uint64, page scanPageFunc) ([]string, uint64, error) {
+		start := time.Now()
+		keys, next, err := page(ctx, cursor)
+		r.observe("ScanKeys", pattern, start, err)
+		return keys, next, err
+	})
+}
+
+// ValuesByKeys labels the call with the prefix of its first key.
+func (r *InstrumentedRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	var prefixKey string
+	if len(keys) > 0 {
+		prefixKey = keys[0]
+	}
+
+	start := time.Now()
+	values, err := r.repo.ValuesByKeys(ctx, keys)
+	r.observe("ValuesByKeys", prefixKey, start, err)
+	if err == nil {
+		hits := 0
+		for _, v := range values {
+			if v != nil {
+				hits++
+			}
+		}
+		r.recorder.ObserveLookup(r.backend, "ValuesByKeys", r.keyPrefix(prefixKey), hits, len(keys)-hits)
+	}
+	return values, err
+}
+
+// GetMany labels the call with the prefix of its first key.
+func (r *InstrumentedRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	var prefixKey string
+	if len(keys) > 0 {
+		prefixKey = keys[0]
+	}
+
+	start := time.Now()
+	results, err := GetMany(ctx, r.repo, keys)
+	r.observe("GetMany", prefixKey, start, err)
+	if err == nil {
+		hits := 0
+		for _, result := range results {
+			if result.Found {
+				hits++
+			}
+		}
+		r.recorder.ObserveLookup(r.backend, "GetMany", r.keyPrefix(prefixKey), hits, len(keys)-hits)
+	}
+	return results, err
+}
+
+func (r *InstrumentedRepo) Unwrap() CacheRepo {
+	return r.repo
+}
+
+func (r *InstrumentedRepo) Close() error {
+	start := time.Now()
+	err := r.repo.Close()
+	r.observe("Close", "", start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := r.repo.Ping(ctx)
+	r.observe("Ping", "", start, err)
+	return err
+}
+
+func (r *InstrumentedRepo) ObserveLoader(ctx context.Context, key string, duration time.Duration, err error) {
+	r.recorder.ObserveLoader(r.backend, r.keyPrefix(key), duration, err)
+}
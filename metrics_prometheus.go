@@ -0,0 +1,240 @@
+package cache_go
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram upper bounds, in seconds, used by
+// NewPrometheusRecorder when no buckets are given.
+var DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+type promMetric struct {
+	name string
+	help string
+	kind string
+}
+
+type promHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// PrometheusRecorder is a MetricsRecorder keeping its measurements in memory
+// and serving them in the Prometheus text exposition format, so it can be
+// mounted on /metrics without depending on the Prometheus client library.
+type PrometheusRecorder struct {
+	buckets []float64
+
+	operations     promMetric
+	errors         promMetric
+	latency        promMetric
+	hits           promMetric
+	misses         promMetric
+	loaderCalls    promMetric
+	loaderErrors   promMetric
+	loaderDuration promMetric
+
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]map[string]*promHistogram
+}
+
+// NewPrometheusRecorder creates a recorder whose metric names start with
+// namespace, e.g. "myapp" gives "myapp_cache_operations_total".
+func NewPrometheusRecorder(namespace string, buckets []float64) *PrometheusRecorder {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	name := func(s string) string {
+		if namespace == "" {
+			return "cache_" + s
+		}
+		return namespace + "_cache_" + s
+	}
+
+	return &PrometheusRecorder{
+		buckets:        buckets,
+		operations:     promMetric{name("operations_total"), "Number of cache operations.", "counter"},
+		errors:         promMetric{name("operation_errors_total"), "Number of failed cache operations.", "counter"},
+		latency:        promMetric{name("operation_duration_seconds"), "Latency of cache operations.", "histogram"},
+		hits:           promMetric{name("hits_total"), "Number of keys found by cache lookups.", "counter"},
+		misses:         promMetric{name("misses_total"), "Number of keys missed by cache lookups.", "counter"},
+		loaderCalls:    promMetric{name("loader_calls_total"), "Number of calls to the GetFromCache loader.", "counter"},
+		loaderErrors:   promMetric{name("loader_errors_total"), "Number of failed calls to the GetFromCache loader.", "counter"},
+		loaderDuration: promMetric{name("loader_duration_seconds"), "Latency of the GetFromCache loader.", "histogram"},
+		counters:       make(map[string]map[string]float64),
+		histograms:     make(map[string]map[string]*promHistogram),
+	}
+}
+
+func (p *PrometheusRecorder) ObserveOperation(backend string, operation string, prefix string, duration time.Duration, err error) {
+	labels := promLabels("backend", backend, "operation", operation, "prefix", prefix)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.add(p.operations, labels, 1)
+	if err != nil {
+		p.add(p.errors, labels, 1)
+	}
+	p.observe(p.latency, labels, duration)
+}
+
+func (p *PrometheusRecorder) ObserveLookup(backend string, operation string, prefix string, hits int, misses int) {
+	labels := promLabels("backend", backend, "operation", operation, "prefix", prefix)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.add(p.hits, labels, float64(hits))
+	p.add(p.misses, labels, float64(misses))
+}
+
+func (p *PrometheusRecorder) ObserveLoader(backend string, prefix string, duration time.Duration, err error) {
+	labels := promLabels("backend", backend, "prefix", prefix)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.add(p.loaderCalls, labels, 1)
+	if err != nil {
+		p.add(p.loaderErrors, labels, 1)
+	}
+	p.observe(p.loaderDuration, labels, duration)
+}
+
+// add increments a counter. The caller must hold p.mu.
+func (p *PrometheusRecorder) add(metric promMetric, labels string, v float64) {
+	series, ok := p.counters[metric.name]
+	if !ok {
+		series = make(map[string]float64)
+		p.counters[metric.name] = series
+	}
+	series[labels] += v
+}
+
+// observe records a histogram sample. The caller must hold p.mu.
+func (p *PrometheusRecorder) observe(metric promMetric, labels string, d time.Duration) {
+	series, ok := p.histograms[metric.name]
+	if !ok {
+		series = make(map[string]*promHistogram)
+		p.histograms[metric.name] = series
+	}
+	h, ok := series[labels]
+	if !ok {
+		h = &promHistogram{counts: make([]uint64, len(p.buckets))}
+		series[labels] = h
+	}
+
+	seconds := d.Seconds()
+	h.count++
+	h.sum += seconds
+	if i := sort.SearchFloat64s(p.buckets, seconds); i < len(p.buckets) {
+		h.counts[i]++
+	}
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format.
+func (p *PrometheusRecorder) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, metric := range []promMetric{p.operations, p.errors, p.hits, p.misses, p.loaderCalls, p.loaderErrors} {
+		series := p.counters[metric.name]
+		if len(series) == 0 {
+			continue
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, labels := range sortedKeys(series) {
+			fmt.Fprintf(cw, "%s{%s} %s\n", metric.name, labels, formatPromFloat(series[labels]))
+		}
+	}
+
+	for _, metric := range []promMetric{p.latency, p.loaderDuration} {
+		series := p.histograms[metric.name]
+		if len(series) == 0 {
+			continue
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, labels := range sortedKeys(series) {
+			h := series[labels]
+			var cumulative uint64
+			for i, bound := range p.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(cw, "%s_bucket{%s,le=\"%s\"} %d\n", metric.name, labels, formatPromFloat(bound), cumulative)
+			}
+			fmt.Fprintf(cw, "%s_bucket{%s,le=\"+Inf\"} %d\n", metric.name, labels, h.count)
+			fmt.Fprintf(cw, "%s_sum{%s} %s\n", metric.name, labels, formatPromFloat(h.sum))
+			fmt.Fprintf(cw, "%s_count{%s} %d\n", metric.name, labels, h.count)
+		}
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func (p *PrometheusRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promLabels formats name/value pairs as the inside of a Prometheus label set.
+func promLabels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(promLabelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func formatPromFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
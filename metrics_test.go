@@ -0,0 +1,191 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedOperation struct {
+	backend   string
+	operation string
+	prefix    string
+	failed    bool
+}
+
+type fakeRecorder struct {
+	mu         sync.Mutex
+	operations []recordedOperation
+	hits       int
+	misses     int
+	loads      []string
+}
+
+func (f *fakeRecorder) ObserveOperation(backend string, operation string, prefix string, duration time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.operations = append(f.operations, recordedOperation{backend, operation, prefix, err != nil})
+}
+
+func (f *fakeRecorder) ObserveLookup(backend string, operation string, prefix string, hits int, misses int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hits += hits
+	f.misses += misses
+}
+
+func (f *fakeRecorder) ObserveLoader(backend string, prefix string, duration time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loads = append(f.loads, prefix)
+}
+
+func TestDefaultKeyPrefix(t *testing.T) {
+	assert.Equal(t, "user", DefaultKeyPrefix("user:42"))
+	assert.Equal(t, "user", DefaultKeyPrefix("user:42:profile"))
+	assert.Equal(t, "plain", DefaultKeyPrefix("plain"))
+}
+
+func TestInstrumentedRepo(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	repo := NewInstrumentedRepo(NewMemoryCache(), recorder, InstrumentOptions{Backend: "memory"})
+
+	assert.NoError(t, repo.Store(ctx, "user:1", []byte("a"), time.Minute))
+	_, _, _ = repo.Get(ctx, "user:1")
+	_, _, _ = repo.Get(ctx, "user:2")
+	_, _ = repo.ValuesByKeys(ctx, []string{"user:1", "user:2", "user:3"})
+
+	assert.Equal(t, []recordedOperation{
+		{"memory", "Store", "user", false},
+		{"memory", "Get", "user", false},
+		{"memory", "Get", "user", false},
+		{"memory", "ValuesByKeys", "user", false},
+	}, recorder.operations)
+	assert.Equal(t, 2, recorder.hits)
+	assert.Equal(t, 3, recorder.misses)
+}
+
+func TestInstrumentedRepo_ScanKeys(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	memory := NewMemoryCache()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, memory.Store(ctx, fmt.Sprintf("user:%d", i), []byte("a"), time.Minute))
+	}
+	repo := NewInstrumentedRepo(memory, recorder, InstrumentOptions{Backend: "memory"})
+
+	it := ScanKeys(ctx, repo, "user:*", 2)
+	for it.Next() {
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []recordedOperation{
+		{"memory", "ScanKeys", "user", false},
+		{"memory", "ScanKeys", "user", false},
+	}, recorder.operations)
+}
+
+func TestInstrumentedRepo_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	mockRepo.EXPECT().Get(ctx, "user:1").Return(nil, false, errors.New("cache error"))
+
+	recorder := &fakeRecorder{}
+	repo := NewInstrumentedRepo(mockRepo, recorder, InstrumentOptions{
+		Backend:   "redis",
+		KeyPrefix: func(key string) string { return "all" },
+	})
+
+	_, _, err := repo.Get(ctx, "user:1")
+	assert.Error(t, err)
+	assert.Equal(t, []recordedOperation{{"redis", "Get", "all", true}}, recorder.operations)
+	assert.Equal(t, 0, recorder.hits+recorder.misses, "failed lookups are neither hits nor misses")
+}
+
+func TestGetFromCache_ObservesLoader(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	repo := NewInstrumentedRepo(NewMemoryCache(), recorder, InstrumentOptions{Backend: "memory"})
+	load := func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, load)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"test"}, recorder.loads, "only the first call should reach the loader")
+}
+
+func TestGetFromCache_ObservesLoaderThroughDecorators(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	instrumented := NewInstrumentedRepo(NewMemoryCache(), recorder, InstrumentOptions{Backend: "memory"})
+	repo := NewRetryingRepo(instrumented, RetryOptions{})
+	load := func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	}
+
+	_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, load)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test"}, recorder.loads)
+}
+
+func TestGetFromCache_ObservesLoaderUnderNamespace(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	instrumented := NewInstrumentedRepo(NewMemoryCache(), recorder, InstrumentOptions{Backend: "memory"})
+	repo := Namespaced(instrumented, "tenant:")
+	load := func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	}
+
+	// The loader is labelled like the lookups, with the namespaced key
+	_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, load)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant"}, recorder.loads)
+	assert.Equal(t, "tenant", recorder.operations[0].prefix)
+}
+
+func TestPrometheusRecorder(t *testing.T) {
+	recorder := NewPrometheusRecorder("app", []float64{0.01, 0.1})
+	recorder.ObserveOperation("redis", "Get", "user", 5*time.Millisecond, nil)
+	recorder.ObserveOperation("redis", "Get", "user", 50*time.Millisecond, errors.New("boom"))
+	recorder.ObserveLookup("redis", "Get", "user", 1, 0)
+	recorder.ObserveLoader("redis", "user\"x", time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	recorder.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	for _, line := range []string{
+		"# TYPE app_cache_operations_total counter",
+		`app_cache_operations_total{backend="redis",operation="Get",prefix="user"} 2`,
+		`app_cache_operation_errors_total{backend="redis",operation="Get",prefix="user"} 1`,
+		`app_cache_hits_total{backend="redis",operation="Get",prefix="user"} 1`,
+		`app_cache_misses_total{backend="redis",operation="Get",prefix="user"} 0`,
+		"# TYPE app_cache_operation_duration_seconds histogram",
+		`app_cache_operation_duration_seconds_bucket{backend="redis",operation="Get",prefix="user",le="0.01"} 1`,
+		`app_cache_operation_duration_seconds_bucket{backend="redis",operation="Get",prefix="user",le="0.1"} 2`,
+		`app_cache_operation_duration_seconds_bucket{backend="redis",operation="Get",prefix="user",le="+Inf"} 2`,
+		`app_cache_operation_duration_seconds_count{backend="redis",operation="Get",prefix="user"} 2`,
+		`app_cache_loader_calls_total{backend="redis",prefix="user\"x"} 1`,
+		`app_cache_loader_duration_seconds_bucket{backend="redis",prefix="user\"x",le="0.1"} 0`,
+	} {
+		assert.Contains(t, strings.Split(body, "\n"), line)
+	}
+}
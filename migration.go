@@ -0,0 +1,310 @@
+package cache_go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+type MismatchKind int
+
+const (
+	// MismatchMissingOld means the key was found in the new backend only.
+	MismatchMissingOld MismatchKind = iota
+	// MismatchMissingNew means the key was found in the old backend only.
+	MismatchMissingNew
+	// MismatchValue means both backends hold the key with different bytes.
+	MismatchValue
+)
+
+func (k MismatchKind) String() string {
+	switch k {
+	case MismatchMissingOld:
+		return "missing in old"
+	case MismatchMissingNew:
+		return "missing in new"
+	case MismatchValue:
+		return "different value"
+	}
+	return "unknown"
+}
+
+// Mismatch is a difference between the two backends found by a shadow read.
+type Mismatch struct {
+	Key  string
+	Kind MismatchKind
+}
+
+type MigrationOptions struct {
+	// ReadPercent is the share of reads served by the new backend, from 0 to 100.
+	ReadPercent int
+	// ShadowReads also reads every Get from the other backend, in the
+	// background, and compares both replies.
+	ShadowReads bool
+	// ShadowTimeout bounds each shadow read, 1s if zero.
+	ShadowTimeout time.Duration
+	// MaxShadowReads is how many shadow reads may run at once, 100 if zero.
+	// Reads made while all of them are running are not shadowed.
+	MaxShadowReads int
+	// OnMismatch is called for every difference a shadow read finds.
+	OnMismatch func(m Mismatch)
+}
+
+// MigrationStats accumulates the shadow reads of a MigratingRepo since it was created.
+type MigrationStats struct {
+	// ShadowReads counts the shadow reads compared, failed ones excluded.
+	ShadowReads int64
+	// ShadowErrors counts the shadow reads that failed on either backend.
+	ShadowErrors int64
+	// ShadowDropped counts the reads not shadowed because MaxShadowReads
+	// shadow reads were already running.
+	ShadowDropped int64
+	// MissingOld, MissingNew and DifferentValues count the mismatches by kind.
+	MissingOld      int64
+	MissingNew      int64
+	DifferentValues int64
+}
+
+// MigratingRepo is a CacheRepo moving traffic from an old backend to a new
+// one. Writes go to the old backend and then to the new one, and reads go to
+// the new backend for ReadPercent of the calls. Errors from either backend
+// are returned; wrap the new backend in a CircuitBreakerRepo so it can't
+// break writes while it is being brought up.
+type MigratingRepo struct {
+	oldRepo       CacheRepo
+	newRepo       CacheRepo
+	readPercent   atomic.Int32
+	shadowReads   bool
+	shadowTimeout time.Duration
+	shadowSlots   chan struct{}
+	onMismatch    func(m Mismatch)
+
+	shadowed        atomic.Int64
+	shadowErrors    atomic.Int64
+	shadowDropped   atomic.Int64
+	missingOld      atomic.Int64
+	missingNew      atomic.Int64
+	differentValues atomic.Int64
+}
+
+func NewMigratingRepo(oldRepo CacheRepo, newRepo CacheRepo, opts MigrationOptions) *MigratingRepo {
+	if opts.ShadowTimeout <= 0 {
+		opts.ShadowTimeout = time.Second
+	}
+	if opts.MaxShadowReads <= 0 {
+		opts.MaxShadowReads = 100
+	}
+
+	m := &MigratingRepo{
+		oldRepo:       oldRepo,
+		newRepo:       newRepo,
+		shadowReads:   opts.ShadowReads,
+		shadowTimeout: opts.ShadowTimeout,
+		shadowSlots:   make(chan struct{}, opts.MaxShadowReads),
+		onMismatch:    opts.OnMismatch,
+	}
+	m.SetReadPercent(opts.ReadPercent)
+	return m
+}
+
+// SetReadPercent changes the share of reads served by the new backend,
+// clamped to 0..100. It is safe to call while the repo is in use.
+func (m *MigratingRepo) SetReadPercent(percent int) {
+	m.readPercent.Store(int32(min(max(percent, 0), 100)))
+}
+
+func (m *MigratingRepo) ReadPercent() int {
+	return int(m.readPercent.Load())
+}
+
+func (m *MigratingRepo) Stats() MigrationStats {
+	return MigrationStats{
+		ShadowReads:     m.shadowed.Load(),
+		ShadowErrors:    m.shadowErrors.Load(),
+		ShadowDropped:   m.shadowDropped.Load(),
+		MissingOld:      m.missingOld.Load(),
+		MissingNew:      m.missingNew.Load(),
+		DifferentValues: m.differentValues.Load(),
+	}
+}
+
+// pick returns the backend serving this read and the other one.
+func (m *MigratingRepo) pick() (primary CacheRepo, secondary CacheRepo, fromNew bool) {
+	percent := int(m.readPercent.Load())
+	if percent >= 100 || (percent > 0 && rand.Intn(100) < percent) {
+		return m.newRepo, m.oldRepo, true
+	}
+	return m.oldRepo, m.newRepo, false
+}
+
+// write calls fn on the old backend and then on the new one, and joins their errors.
+func (m *MigratingRepo) write(fn func(repo CacheRepo) error) error {
+	return errors.Join(fn(m.oldRepo), fn(m.newRepo))
+}
+
+// startShadow runs shadow in the background, unless MaxShadowReads shadow
+// reads are already running.
+func (m *MigratingRepo) startShadow(ctx context.Context, secondary CacheRepo, fromNew bool, key string, value []byte, found bool) {
+	select {
+	case m.shadowSlots <- struct{}{}:
+	default:
+		m.shadowDropped.Add(1)
+		return
+	}
+
+	go func() {
+		defer func() { <-m.shadowSlots }()
+
+		// The caller may cancel ctx as soon as Get returns.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.shadowTimeout)
+		defer cancel()
+		m.shadow(ctx, secondary, fromNew, key, value, found)
+	}()
+}
+
+// shadow reads key from secondary and compares it with the primary's reply.
+func (m *MigratingRepo) shadow(ctx context.Context, secondary CacheRepo, fromNew bool, key string, value []byte, found bool) {
+	otherValue, otherFound, err := secondary.Get(ctx, key)
+	if err != nil {
+		m.shadowErrors.Add(1)
+		return
+	}
+	m.shadowed.Add(1)
+
+	oldFound, newFound := found, otherFound
+	if fromNew {
+		oldFound, newFound = otherFound, found
+	}
+
+	var kind MismatchKind
+	switch {
+	case oldFound && newFound:
+		if bytes.Equal(value, otherValue) {
+			return
+		}
+		kind = MismatchValue
+		m.differentValues.Add(1)
+	case newFound:
+		kind = MismatchMissingOld
+		m.missingOld.Add(1)
+	case oldFound:
+		kind = MismatchMissingNew
+		m.missingNew.Add(1)
+	default:
+		return
+	}
+
+	if m.onMismatch != nil {
+		m.onMismatch(Mismatch{Key: key, Kind: kind})
+	}
+}
+
+func (m *MigratingRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.Store(ctx, key, value, exp)
+	})
+}
+
+func (m *MigratingRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.StoreWithoutTTL(ctx, key, value)
+	})
+}
+
+// Get shadow-reads the other backend in the background when ShadowReads is
+// set, so the reply is not delayed by the comparison.
+func (m *MigratingRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	primary, secondary, fromNew := m.pick()
+	value, found, err := primary.Get(ctx, key)
+	if err == nil && m.shadowReads {
+		m.startShadow(ctx, secondary, fromNew, key, value, found)
+	}
+	return value, found, err
+}
+
+func (m *MigratingRepo) Delete(ctx context.Context, key string) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.Delete(ctx, key)
+	})
+}
+
+// Increment returns the old backend's counter value until ReadPercent is
+// 100, so successive calls never switch between the two counters.
+func (m *MigratingRepo) Increment(ctx context.Context, key string) (int64, error) {
+	oldVal, oldErr := m.oldRepo.Increment(ctx, key)
+	newVal, newErr := m.newRepo.Increment(ctx, key)
+	if m.ReadPercent() == 100 {
+		return newVal, errors.Join(oldErr, newErr)
+	}
+	return oldVal, errors.Join(oldErr, newErr)
+}
+
+// IncrementWithTTL returns the old backend's counter value until ReadPercent is
+// 100, so successive calls never switch between the two counters.
+func (m *MigratingRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	oldVal, oldErr := m.oldRepo.IncrementWithTTL(ctx, key, exp)
+	newVal, newErr := m.newRepo.IncrementWithTTL(ctx, key, exp)
+	if m.ReadPercent() == 100 {
+		return newVal, errors.Join(oldErr, newErr)
+	}
+	return oldVal, errors.Join(oldErr, newErr)
+}
+
+func (m *MigratingRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.LPush(ctx, key, value)
+	})
+}
+
+func (m *MigratingRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	primary, _, _ := m.pick()
+	return primary.LRange(ctx, key, start, end)
+}
+
+func (m *MigratingRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.LTrim(ctx, key, start, end)
+	})
+}
+
+func (m *MigratingRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return m.write(func(repo CacheRepo) error {
+		return repo.LRem(ctx, key, count, value)
+	})
+}
+
+func (m *MigratingRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	primary, _, _ := m.pick()
+	return primary.KeysByPattern(ctx, pattern)
+}
+
+func (m *MigratingRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	primary, _, _ := m.pick()
+	return ScanKeys(ctx, primary, pattern, batchSize)
+}
+
+func (m *MigratingRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	primary, _, _ := m.pick()
+	return primary.ValuesByKeys(ctx, keys)
+}
+
+func (m *MigratingRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	primary, _, _ := m.pick()
+	return GetMany(ctx, primary, keys)
+}
+
+// Capabilities reports what both backends can do.
+func (m *MigratingRepo) Capabilities() Capabilities {
+	return CapabilitiesOf(m.oldRepo).intersect(CapabilitiesOf(m.newRepo))
+}
+
+func (m *MigratingRepo) Close() error {
+	return errors.Join(m.oldRepo.Close(), m.newRepo.Close())
+}
+
+func (m *MigratingRepo) Ping(ctx context.Context) error {
+	return errors.Join(m.oldRepo.Ping(ctx), m.newRepo.Ping(ctx))
+}
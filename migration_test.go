@@ -0,0 +1,140 @@
+package cache_go
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigratingRepo(t *testing.T) {
+	ctx := context.Background()
+	oldRepo, newRepo := NewMemoryCache(), NewMemoryCache()
+	repo := NewMigratingRepo(oldRepo, newRepo, MigrationOptions{})
+
+	require.NoError(t, repo.Store(ctx, "key", []byte("value"), time.Minute))
+	for _, backend := range []CacheRepo{oldRepo, newRepo} {
+		value, found, err := backend.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "value", string(value))
+	}
+
+	require.NoError(t, oldRepo.Store(ctx, "diverged", []byte("old"), time.Minute))
+	require.NoError(t, newRepo.Store(ctx, "diverged", []byte("new"), time.Minute))
+
+	value, _, err := repo.Get(ctx, "diverged")
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(value))
+
+	repo.SetReadPercent(100)
+	assert.Equal(t, 100, repo.ReadPercent())
+	value, _, err = repo.Get(ctx, "diverged")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(value))
+
+	repo.SetReadPercent(150)
+	assert.Equal(t, 100, repo.ReadPercent())
+}
+
+func TestMigratingRepo_Increment(t *testing.T) {
+	ctx := context.Background()
+	oldRepo, newRepo := NewMemoryCache(), NewMemoryCache()
+	require.NoError(t, newRepo.StoreWithoutTTL(ctx, "counter", []byte("100")))
+	repo := NewMigratingRepo(oldRepo, newRepo, MigrationOptions{ReadPercent: 50})
+
+	// The old counter is returned until every read goes to the new backend
+	for i := int64(1); i <= 20; i++ {
+		value, err := repo.Increment(ctx, "counter")
+		require.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	repo.SetReadPercent(100)
+	value, err := repo.IncrementWithTTL(ctx, "counter", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(121), value)
+}
+
+func TestMigratingRepo_ShadowReads(t *testing.T) {
+	ctx := context.Background()
+	oldRepo, newRepo := NewMemoryCache(), NewMemoryCache()
+	mismatches := make(chan Mismatch, 3)
+	repo := NewMigratingRepo(oldRepo, newRepo, MigrationOptions{
+		ShadowReads: true,
+		OnMismatch: func(m Mismatch) {
+			mismatches <- m
+		},
+	})
+
+	require.NoError(t, oldRepo.Store(ctx, "only-old", []byte("value"), time.Minute))
+	require.NoError(t, newRepo.Store(ctx, "only-new", []byte("value"), time.Minute))
+	require.NoError(t, oldRepo.Store(ctx, "diverged", []byte("old"), time.Minute))
+	require.NoError(t, newRepo.Store(ctx, "diverged", []byte("new"), time.Minute))
+	require.NoError(t, repo.Store(ctx, "same", []byte("value"), time.Minute))
+
+	for _, key := range []string{"only-old", "only-new", "diverged", "same", "absent"} {
+		_, _, err := repo.Get(ctx, key)
+		require.NoError(t, err)
+	}
+
+	got := map[string]MismatchKind{}
+	for i := 0; i < 3; i++ {
+		select {
+		case m := <-mismatches:
+			got[m.Key] = m.Kind
+		case <-time.After(time.Second):
+			t.Fatal("mismatch not reported")
+		}
+	}
+	assert.Equal(t, map[string]MismatchKind{
+		"only-old": MismatchMissingNew,
+		"only-new": MismatchMissingOld,
+		"diverged": MismatchValue,
+	}, got)
+
+	assert.Eventually(t, func() bool {
+		return repo.Stats().ShadowReads == 5
+	}, time.Second, time.Millisecond)
+	stats := repo.Stats()
+	assert.Equal(t, int64(1), stats.MissingOld)
+	assert.Equal(t, int64(1), stats.MissingNew)
+	assert.Equal(t, int64(1), stats.DifferentValues)
+}
+
+// blockingGetCache blocks Get until its context is done.
+type blockingGetCache struct {
+	*MemoryCache
+}
+
+func (b blockingGetCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	<-ctx.Done()
+	return nil, false, ctx.Err()
+}
+
+func TestMigratingRepo_ShadowReadLimits(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMigratingRepo(NewMemoryCache(), blockingGetCache{NewMemoryCache()}, MigrationOptions{
+		ShadowReads:    true,
+		ShadowTimeout:  20 * time.Millisecond,
+		MaxShadowReads: 1,
+	})
+
+	// The second read finds the only shadow read still running
+	for i := 0; i < 2; i++ {
+		_, _, err := repo.Get(ctx, "key")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), repo.Stats().ShadowDropped)
+
+	// The shadow read times out and frees its slot
+	assert.Eventually(t, func() bool {
+		return repo.Stats().ShadowErrors == 1
+	}, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, _, _ = repo.Get(ctx, "key")
+		return repo.Stats().ShadowErrors == 2
+	}, time.Second, 30*time.Millisecond)
+}
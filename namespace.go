@@ -0,0 +1,179 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// PatternDeleter is implemented by backends that can delete every key
+// matching a pattern without loading all of them at once.
+type PatternDeleter interface {
+	DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+}
+
+// NamespacedRepo is a CacheRepo decorator isolating one tenant's keys inside
+// a shared backend by prefixing every key.
+type NamespacedRepo struct {
+	repo   CacheRepo
+	prefix string
+}
+
+// Namespaced prepends prefix to every key as is, so include a separator such
+// as "orders:" in it.
+func Namespaced(repo CacheRepo, prefix string) *NamespacedRepo {
+	return &NamespacedRepo{
+		repo:   repo,
+		prefix: prefix,
+	}
+}
+
+func (n *NamespacedRepo) Prefix() string {
+	return n.prefix
+}
+
+func (n *NamespacedRepo) key(key string) string {
+	return n.prefix + key
+}
+
+// pattern scopes pattern to the namespace, escaping glob characters in the
+// prefix so it only ever matches itself.
+func (n *NamespacedRepo) pattern(pattern string) string {
+	return escapeGlob(n.prefix) + pattern
+}
+
+func (n *NamespacedRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	return n.repo.Store(ctx, n.key(key), value, exp)
+}
+
+func (n *NamespacedRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	return n.repo.StoreWithoutTTL(ctx, n.key(key), value)
+}
+
+func (n *NamespacedRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return n.repo.Get(ctx, n.key(key))
+}
+
+func (n *NamespacedRepo) Delete(ctx context.Context, key string) error {
+	return n.repo.Delete(ctx, n.key(key))
+}
+
+func (n *NamespacedRepo) Increment(ctx context.Context, key string) (int64, error) {
+	return n.repo.Increment(ctx, n.key(key))
+}
+
+func (n *NamespacedRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	return n.repo.IncrementWithTTL(ctx, n.key(key), exp)
+}
+
+func (n *NamespacedRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return n.repo.LPush(ctx, n.key(key), value)
+}
+
+func (n *NamespacedRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	return n.repo.LRange(ctx, n.key(key), start, end)
+}
+
+func (n *NamespacedRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return n.repo.LTrim(ctx, n.key(key), start, end)
+}
+
+func (n *NamespacedRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return n.repo.LRem(ctx, n.key(key), count, value)
+}
+
+// KeysByPattern matches pattern inside the namespace only and returns keys
+// without the prefix.
+func (n *NamespacedRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	keys, err := n.repo.KeysByPattern(ctx, n.pattern(pattern))
+
+	stripped := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if strings.HasPrefix(k, n.prefix) {
+			stripped = append(stripped, strings.TrimPrefix(k, n.prefix))
+		}
+	}
+	return stripped, err
+}
+
+// ScanKeys scans pattern inside the namespace only and returns keys without
+// the prefix.
+func (n *NamespacedRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	it := ScanKeys(ctx, n.repo, n.pattern(pattern), batchSize)
+	return mapKeys(ctx, it, func(keys []string) []string {
+		stripped := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if strings.HasPrefix(k, n.prefix) {
+				stripped = append(stripped, strings.TrimPrefix(k, n.prefix))
+			}
+		}
+		return stripped
+	})
+}
+
+func (n *NamespacedRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = n.key(k)
+	}
+	return n.repo.ValuesByKeys(ctx, prefixed)
+}
+
+func (n *NamespacedRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = n.key(k)
+	}
+	return GetMany(ctx, n.repo, prefixed)
+}
+
+func (n *NamespacedRepo) Unwrap() CacheRepo {
+	return n.repo
+}
+
+func (n *NamespacedRepo) Close() error {
+	return n.repo.Close()
+}
+
+func (n *NamespacedRepo) Ping(ctx context.Context) error {
+	return n.repo.Ping(ctx)
+}
+
+// DeleteByPattern deletes the keys matching pattern inside the namespace.
+func (n *NamespacedRepo) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	if deleter, ok := n.repo.(PatternDeleter); ok {
+		return deleter.DeleteByPattern(ctx, n.pattern(pattern))
+	}
+
+	keys, err := n.repo.KeysByPattern(ctx, n.pattern(pattern))
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, k := range keys {
+		if err = n.repo.Delete(ctx, k); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// FlushNamespace deletes every key of the namespace and returns how many
+// were deleted. On RedisCache it scans and unlinks in batches, so it never
+// blocks the server or loads the whole keyspace.
+func (n *NamespacedRepo) FlushNamespace(ctx context.Context) (int64, error) {
+	if n.prefix == "" {
+		return 0, errors.New("cache_go: refusing to flush an empty namespace")
+	}
+	return n.DeleteByPattern(ctx, "*")
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapeGlob escapes s so it matches itself literally in a glob pattern.
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
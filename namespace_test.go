@@ -0,0 +1,73 @@
+package cache_go
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNamespaced checks two tenants sharing repo can't see each other's keys.
+func testNamespaced(t *testing.T, repo CacheRepo) {
+	ctx := context.Background()
+	tenantA := Namespaced(repo, "tenant[a]:")
+	tenantB := Namespaced(repo, "tenant-b:")
+	_, _ = tenantA.FlushNamespace(ctx)
+	_, _ = tenantB.FlushNamespace(ctx)
+
+	require.NoError(t, tenantA.Store(ctx, "user:1", []byte("a1"), time.Minute))
+	require.NoError(t, tenantA.Store(ctx, "user:2", []byte("a2"), time.Minute))
+	require.NoError(t, tenantB.Store(ctx, "user:1", []byte("b1"), time.Minute))
+
+	value, found, err := repo.Get(ctx, "tenant[a]:user:1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "a1", string(value))
+
+	value, _, _ = tenantB.Get(ctx, "user:1")
+	assert.Equal(t, "b1", string(value))
+
+	keys, err := tenantA.KeysByPattern(ctx, "*")
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	deleted, err := tenantA.FlushNamespace(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	keys, err = tenantA.KeysByPattern(ctx, "*")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, found, _ = tenantB.Get(ctx, "user:1")
+	assert.True(t, found, "flushing one tenant must not touch another")
+	_, _ = tenantB.FlushNamespace(ctx)
+}
+
+func TestNamespacedRepo_MemoryCache(t *testing.T) {
+	testNamespaced(t, NewMemoryCache())
+}
+
+func TestNamespacedRepo_WithoutPatternDeleter(t *testing.T) {
+	// SafeKeyRepo leaves short keys untouched and has no DeleteByPattern
+	testNamespaced(t, NewSafeKeyRepo(NewMemoryCache()))
+}
+
+func TestNamespacedRepo_ValuesByKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := Namespaced(NewMemoryCache(), "svc:")
+
+	require.NoError(t, repo.Store(ctx, "a", []byte("1"), time.Minute))
+	values, err := repo.ValuesByKeys(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("1"), nil}, values)
+}
+
+func TestNamespacedRepo_FlushEmptyNamespace(t *testing.T) {
+	_, err := Namespaced(NewMemoryCache(), "").FlushNamespace(context.Background())
+	assert.Error(t, err)
+}
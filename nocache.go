@@ -2,6 +2,7 @@ package cache_go
 
 import (
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -52,12 +53,24 @@ func (n NocacheRepo) LRem(ctx context.Context, key string, count int64, value []
 	return nil
 }
 
+// KeysByPattern fails rather than report no keys, which would pass for a
+// real listing.
 func (n NocacheRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
-	return []string{}, nil
+	return nil, fmt.Errorf("nocache: pattern-based key search: %w", ErrNotSupported)
 }
 
 func (n NocacheRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
-	return []interface{}{}, nil
+	return make([]interface{}, len(keys)), nil
+}
+
+func (n NocacheRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	return make([]Result, len(keys)), nil
+}
+
+// Capabilities reports nothing: NocacheRepo stores nothing, so its empty
+// results say nothing about the keys a real backend would hold.
+func (n NocacheRepo) Capabilities() Capabilities {
+	return Capabilities{}
 }
 
 func (n NocacheRepo) Close() error {
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+)
+
+const fixedWindowScript = `
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`
+
+// FixedWindow allows limit requests per key in each window-aligned interval.
+// Bursts of up to 2*limit are possible around a window boundary.
+type FixedWindow struct {
+	repo   cache_go.CacheRepo
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewFixedWindow(repo cache_go.CacheRepo, limit int64, window time.Duration) (*FixedWindow, error) {
+	if err := validate(limit, window); err != nil {
+		return nil, err
+	}
+
+	return &FixedWindow{
+		repo:   repo,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+func (f *FixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	now := f.now()
+	windowStart := now.Truncate(f.window)
+	resetAt := windowStart.Add(f.window)
+	windowKey := fmt.Sprintf("%s:%d", key, windowStart.UnixMilli())
+
+	var (
+		count int64
+		err   error
+	)
+	if runner, ok := scriptRunner(f.repo); ok {
+		var reply interface{}
+		reply, err = runner.RunScript(ctx, fixedWindowScript, []string{windowKey}, f.window.Milliseconds())
+		if err == nil {
+			count, _ = reply.(int64)
+		}
+	} else {
+		// The window start is part of the key, so refreshing the TTL on every
+		// increment never extends the window itself.
+		count, err = f.repo.IncrementWithTTL(ctx, windowKey, f.window)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   count <= f.limit,
+		Limit:     f.limit,
+		Remaining: max(f.limit-count, 0),
+		ResetAt:   resetAt,
+	}
+	if !result.Allowed {
+		result.RetryAfter = resetAt.Sub(now)
+	}
+	return result, nil
+}
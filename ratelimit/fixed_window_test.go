@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedWindow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo cache_go.CacheRepo, key string) {
+		clock := newFakeClock()
+		limiter, err := NewFixedWindow(repo, 3, time.Minute)
+		require.NoError(t, err)
+		limiter.now = clock.Now
+
+		result := allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(2), result.Remaining)
+		assert.Equal(t, clock.Now().Add(time.Minute), result.ResetAt)
+
+		result = allowN(t, limiter, key, 2)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+
+		clock.Advance(20 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, 40*time.Second, result.RetryAfter)
+
+		// A new window starts with a fresh quota
+		clock.Advance(40 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(2), result.Remaining)
+	})
+}
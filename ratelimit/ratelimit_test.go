@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	// Aligned to the minute so window boundaries are predictable.
+	return &fakeClock{now: time.Now().Truncate(time.Minute).Add(time.Minute)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func setupTestRedis(t *testing.T) *cache_go.RedisCache {
+	cache := cache_go.NewRedisCache("localhost:6379", "", 0)
+	if err := cache.Ping(context.Background()); err != nil {
+		t.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	t.Cleanup(func() {
+		cache.Close()
+	})
+	return cache
+}
+
+// forEachBackend runs fn against MemoryCache and RedisCache.
+func forEachBackend(t *testing.T, fn func(t *testing.T, repo cache_go.CacheRepo, key string)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, cache_go.NewMemoryCache(), "ratelimit:"+t.Name())
+	})
+	t.Run("redis", func(t *testing.T) {
+		fn(t, setupTestRedis(t), fmt.Sprintf("ratelimit:%s:%d", t.Name(), time.Now().UnixNano()))
+	})
+}
+
+func allowN(t *testing.T, limiter Limiter, key string, n int) Result {
+	t.Helper()
+
+	var result Result
+	for i := 0; i < n; i++ {
+		var err error
+		result, err = limiter.Allow(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+	}
+	return result
+}
+
+func TestResult_SetHeaders(t *testing.T) {
+	h := http.Header{}
+	Result{Allowed: true, Limit: 10, Remaining: 3, ResetAt: time.Now().Add(1500 * time.Millisecond)}.SetHeaders(h)
+	assert.Equal(t, "10", h.Get("RateLimit-Limit"))
+	assert.Equal(t, "3", h.Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", h.Get("RateLimit-Reset"))
+	assert.Empty(t, h.Get("Retry-After"))
+
+	h = http.Header{}
+	Result{Limit: 10, ResetAt: time.Now().Add(time.Minute), RetryAfter: 2500 * time.Millisecond}.SetHeaders(h)
+	assert.Equal(t, "0", h.Get("RateLimit-Remaining"))
+	assert.Equal(t, "3", h.Get("Retry-After"))
+}
+
+func TestNewLimiter_InvalidConfig(t *testing.T) {
+	repo := cache_go.NewMemoryCache()
+	_, err := NewFixedWindow(repo, 0, time.Second)
+	assert.Error(t, err)
+	_, err = NewTokenBucket(repo, 1, 0)
+	assert.Error(t, err)
+	_, err = NewSlidingWindowLog(repo, 1, time.Microsecond)
+	assert.Error(t, err)
+}
+
+func TestScriptRunner_Decorated(t *testing.T) {
+	ctx := context.Background()
+	redis := setupTestRedis(t)
+	key := fmt.Sprintf("ratelimit:%s:%d", t.Name(), time.Now().UnixNano())
+	defer redis.Delete(ctx, "ns:"+key)
+
+	limiter, err := NewTokenBucket(cache_go.Namespaced(redis, "ns:"), 2, time.Minute)
+	require.NoError(t, err)
+	allowN(t, limiter, key, 1)
+
+	// The script would store a hash; the fallback stores a plain value
+	// under the namespaced key
+	_, found, err := redis.Get(ctx, "ns:"+key)
+	require.NoError(t, err)
+	assert.True(t, found)
+}
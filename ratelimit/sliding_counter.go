@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+)
+
+const slidingCounterScript = `
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+
+if previous * weight + current + 1 > limit then
+	return {0, current, previous}
+end
+
+current = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return {1, current, previous}`
+
+// SlidingWindowCounter approximates a sliding window from two fixed window
+// counters, weighting the previous window by how much of it still overlaps
+// the sliding window. It needs two counters per key regardless of limit.
+type SlidingWindowCounter struct {
+	repo   cache_go.CacheRepo
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+func NewSlidingWindowCounter(repo cache_go.CacheRepo, limit int64, window time.Duration) (*SlidingWindowCounter, error) {
+	if err := validate(limit, window); err != nil {
+		return nil, err
+	}
+
+	return &SlidingWindowCounter{
+		repo:   repo,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+func (s *SlidingWindowCounter) Allow(ctx context.Context, key string) (Result, error) {
+	now := s.now()
+	windowStart := now.Truncate(s.window)
+	elapsed := now.Sub(windowStart)
+	weight := float64(s.window-elapsed) / float64(s.window)
+	currentKey := fmt.Sprintf("%s:%d", key, windowStart.UnixMilli())
+	previousKey := fmt.Sprintf("%s:%d", key, windowStart.Add(-s.window).UnixMilli())
+
+	var (
+		allowed           bool
+		current, previous int64
+		err               error
+	)
+	if runner, ok := scriptRunner(s.repo); ok {
+		var reply interface{}
+		reply, err = runner.RunScript(ctx, slidingCounterScript, []string{currentKey, previousKey},
+			s.limit, strconv.FormatFloat(weight, 'f', -1, 64), (2 * s.window).Milliseconds())
+		if err == nil {
+			var values []int64
+			values, err = scriptInts(reply, 3)
+			if err == nil {
+				allowed, current, previous = values[0] == 1, values[1], values[2]
+			}
+		}
+	} else {
+		allowed, current, previous, err = s.allow(ctx, currentKey, previousKey, weight)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	estimate := float64(previous)*weight + float64(current)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     s.limit,
+		Remaining: max(int64(math.Floor(float64(s.limit)-estimate)), 0),
+		ResetAt:   windowStart.Add(s.window),
+	}
+	if current > 0 {
+		// The current window keeps weighing on the estimate during the next one.
+		result.ResetAt = windowStart.Add(2 * s.window)
+	}
+	if !allowed {
+		result.RetryAfter = s.retryAfter(elapsed, current, previous)
+	}
+	return result, nil
+}
+
+func (s *SlidingWindowCounter) allow(ctx context.Context, currentKey string, previousKey string, weight float64) (bool, int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	currentBytes, _, err := s.repo.Get(ctx, currentKey)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	previousBytes, _, err := s.repo.Get(ctx, previousKey)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	current, previous := parseInt64(currentBytes), parseInt64(previousBytes)
+
+	if float64(previous)*weight+float64(current)+1 > float64(s.limit) {
+		return false, current, previous, nil
+	}
+
+	current, err = s.repo.IncrementWithTTL(ctx, currentKey, 2*s.window)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return true, current, previous, nil
+}
+
+// retryAfter returns how long until the estimate leaves room for one more
+// request, assuming no other request is allowed meanwhile.
+func (s *SlidingWindowCounter) retryAfter(elapsed time.Duration, current int64, previous int64) time.Duration {
+	window := float64(s.window)
+	room := float64(s.limit - 1)
+
+	if float64(current) <= room {
+		// Room appears within this window as the previous one fades out.
+		at := window - (room-float64(current))*window/float64(previous)
+		return time.Duration(at) - elapsed
+	}
+
+	// Wait for the next window, where the current count fades out instead.
+	at := window - room*window/float64(current)
+	return s.window - elapsed + time.Duration(at)
+}
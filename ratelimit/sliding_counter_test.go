@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowCounter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo cache_go.CacheRepo, key string) {
+		clock := newFakeClock()
+		limiter, err := NewSlidingWindowCounter(repo, 4, time.Minute)
+		require.NoError(t, err)
+		limiter.now = clock.Now
+
+		result := allowN(t, limiter, key, 4)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, clock.Now().Add(2*time.Minute), result.ResetAt)
+
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		// Room for one more once a quarter of the current count faded out in the next window
+		assert.Equal(t, 75*time.Second, result.RetryAfter)
+
+		// Halfway into the next window the previous count weighs 4 * 0.5 = 2
+		clock.Advance(90 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(1), result.Remaining)
+
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		// The estimate 4*w + 2 drops to 3 when w reaches 1/4
+		assert.Equal(t, 15*time.Second, result.RetryAfter)
+	})
+}
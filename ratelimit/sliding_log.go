@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+)
+
+// The list holds request timestamps in milliseconds, newest first, so the
+// entries still inside the window are always a prefix of the list.
+const slidingLogScript = `
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+local entries = redis.call("LRANGE", KEYS[1], 0, limit)
+local count = 0
+for _, ts in ipairs(entries) do
+	if tonumber(ts) <= now - window then
+		break
+	end
+	count = count + 1
+end
+
+if count == 0 then
+	redis.call("DEL", KEYS[1])
+elseif count < #entries then
+	redis.call("LTRIM", KEYS[1], 0, count - 1)
+end
+
+local oldest = now
+if count > 0 then
+	oldest = tonumber(entries[count])
+end
+
+local allowed = 0
+local newest = now
+if count < limit then
+	redis.call("LPUSH", KEYS[1], now)
+	count = count + 1
+	allowed = 1
+else
+	newest = tonumber(entries[1])
+end
+redis.call("PEXPIRE", KEYS[1], window)
+
+return {allowed, count, oldest, newest}`
+
+// SlidingWindowLog keeps a log of request timestamps per key in a list and
+// allows a request when fewer than limit happened during the last window.
+// It is exact but stores up to limit entries per key.
+type SlidingWindowLog struct {
+	repo   cache_go.CacheRepo
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+func NewSlidingWindowLog(repo cache_go.CacheRepo, limit int64, window time.Duration) (*SlidingWindowLog, error) {
+	if err := validate(limit, window); err != nil {
+		return nil, err
+	}
+
+	return &SlidingWindowLog{
+		repo:   repo,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+func (s *SlidingWindowLog) Allow(ctx context.Context, key string) (Result, error) {
+	now := s.now()
+	nowMs := now.UnixMilli()
+
+	var (
+		allowed bool
+		count   int64
+		oldest  int64
+		newest  int64
+		err     error
+	)
+	if runner, ok := scriptRunner(s.repo); ok {
+		var reply interface{}
+		reply, err = runner.RunScript(ctx, slidingLogScript, []string{key}, nowMs, s.window.Milliseconds(), s.limit)
+		if err == nil {
+			var values []int64
+			values, err = scriptInts(reply, 4)
+			if err == nil {
+				allowed, count, oldest, newest = values[0] == 1, values[1], values[2], values[3]
+			}
+		}
+	} else {
+		allowed, count, oldest, newest, err = s.allow(ctx, key, nowMs)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	// A slot frees up when the oldest request leaves the window, and the
+	// whole quota once the newest one does.
+	result := Result{
+		Allowed:   allowed,
+		Limit:     s.limit,
+		Remaining: max(s.limit-count, 0),
+		ResetAt:   time.UnixMilli(newest).Add(s.window),
+	}
+	if !allowed {
+		result.RetryAfter = time.UnixMilli(oldest).Add(s.window).Sub(now)
+	}
+	return result, nil
+}
+
+func (s *SlidingWindowLog) allow(ctx context.Context, key string, nowMs int64) (bool, int64, int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.repo.LRange(ctx, key, 0, s.limit)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+
+	var count int64
+	for _, entry := range entries {
+		ts, _ := strconv.ParseInt(entry, 10, 64)
+		if ts <= nowMs-s.window.Milliseconds() {
+			break
+		}
+		count++
+	}
+
+	if count == 0 && len(entries) > 0 {
+		err = s.repo.Delete(ctx, key)
+	} else if count < int64(len(entries)) {
+		err = s.repo.LTrim(ctx, key, 0, count-1)
+	}
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+
+	oldest := nowMs
+	if count > 0 {
+		oldest, _ = strconv.ParseInt(entries[count-1], 10, 64)
+	}
+
+	if count >= s.limit {
+		newest, _ := strconv.ParseInt(entries[0], 10, 64)
+		return false, count, oldest, newest, nil
+	}
+
+	if err = s.repo.LPush(ctx, key, []byte(strconv.FormatInt(nowMs, 10))); err != nil {
+		return false, 0, 0, 0, err
+	}
+	return true, count + 1, oldest, nowMs, nil
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo cache_go.CacheRepo, key string) {
+		clock := newFakeClock()
+		limiter, err := NewSlidingWindowLog(repo, 3, time.Minute)
+		require.NoError(t, err)
+		limiter.now = clock.Now
+		start := clock.Now()
+
+		result := allowN(t, limiter, key, 2)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(1), result.Remaining)
+
+		clock.Advance(30 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, start.Add(90*time.Second), result.ResetAt)
+
+		// Unlike a fixed window, crossing a minute boundary frees nothing
+		clock.Advance(20 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 10*time.Second, result.RetryAfter)
+		assert.Equal(t, start.Add(90*time.Second), result.ResetAt)
+
+		// The first two requests leave the window
+		clock.Advance(10 * time.Second)
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(1), result.Remaining)
+
+		entries, err := repo.LRange(context.Background(), key, 0, -1)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2, "expired entries should be trimmed")
+	})
+}
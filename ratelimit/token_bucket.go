@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+)
+
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ts)
+redis.call("PEXPIRE", KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}`
+
+// TokenBucket holds up to limit tokens per key and refills them continuously,
+// a full bucket every window. Each request takes one token, so bursts of up
+// to limit are allowed after a quiet period.
+type TokenBucket struct {
+	repo   cache_go.CacheRepo
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+func NewTokenBucket(repo cache_go.CacheRepo, limit int64, window time.Duration) (*TokenBucket, error) {
+	if err := validate(limit, window); err != nil {
+		return nil, err
+	}
+
+	return &TokenBucket{
+		repo:   repo,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}, nil
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	now := b.now()
+	// tokens per millisecond
+	rate := float64(b.limit) / float64(b.window.Milliseconds())
+
+	var (
+		allowed bool
+		tokens  float64
+		err     error
+	)
+	if runner, ok := scriptRunner(b.repo); ok {
+		var reply interface{}
+		reply, err = runner.RunScript(ctx, tokenBucketScript, []string{key},
+			b.limit, strconv.FormatFloat(rate, 'f', -1, 64), now.UnixMilli(), b.window.Milliseconds())
+		if err == nil {
+			allowed, tokens, err = parseTokenBucketReply(reply)
+		}
+	} else {
+		allowed, tokens, err = b.allow(ctx, key, now.UnixMilli(), rate)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     b.limit,
+		Remaining: int64(math.Floor(tokens)),
+		ResetAt:   now.Add(time.Duration((float64(b.limit) - tokens) / rate * float64(time.Millisecond))),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Millisecond))
+	}
+	return result, nil
+}
+
+func (b *TokenBucket) allow(ctx context.Context, key string, nowMs int64, rate float64) (bool, float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, found, err := b.repo.Get(ctx, key)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, ts := float64(b.limit), nowMs
+	if found {
+		tokens, ts = parseTokenBucketState(state, tokens, ts)
+	}
+	if nowMs > ts {
+		tokens = math.Min(float64(b.limit), tokens+float64(nowMs-ts)*rate)
+		ts = nowMs
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	state = []byte(strconv.FormatFloat(tokens, 'f', -1, 64) + " " + strconv.FormatInt(ts, 10))
+	if err = b.repo.Store(ctx, key, state, b.window); err != nil {
+		return false, 0, err
+	}
+	return allowed, tokens, nil
+}
+
+func parseTokenBucketState(state []byte, tokens float64, ts int64) (float64, int64) {
+	parts := strings.Fields(string(state))
+	if len(parts) != 2 {
+		return tokens, ts
+	}
+	parsedTokens, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return tokens, ts
+	}
+	parsedTs, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return tokens, ts
+	}
+	return parsedTokens, parsedTs
+}
+
+func parseTokenBucketReply(reply interface{}) (bool, float64, error) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	if !ok {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+	}
+	tokensStr, ok := values[1].(string)
+	if !ok {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script reply %v", reply)
+	}
+	return allowed == 1, tokens, nil
+}
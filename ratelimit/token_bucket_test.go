@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	cache_go "github.com/harryosmar/cache-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo cache_go.CacheRepo, key string) {
+		clock := newFakeClock()
+		// One token every 10 seconds, bursts of up to 6
+		limiter, err := NewTokenBucket(repo, 6, time.Minute)
+		require.NoError(t, err)
+		limiter.now = clock.Now
+
+		result := allowN(t, limiter, key, 6)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+		assert.Equal(t, clock.Now().Add(time.Minute), result.ResetAt)
+
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 10*time.Second, result.RetryAfter)
+
+		clock.Advance(25 * time.Second)
+		result = allowN(t, limiter, key, 2)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(0), result.Remaining)
+
+		result = allowN(t, limiter, key, 1)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 5*time.Second, result.RetryAfter)
+
+		// The bucket never holds more than its capacity
+		clock.Advance(time.Hour)
+		result = allowN(t, limiter, key, 1)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, int64(5), result.Remaining)
+	})
+}
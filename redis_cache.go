@@ -2,13 +2,19 @@ package cache_go
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client  *redis.Client
+	scripts sync.Map // script source -> *redis.Script
 }
 
 func NewRedisCache(addr string, password string, db int) *RedisCache {
@@ -99,6 +105,16 @@ func (c *RedisCache) KeysByPattern(ctx context.Context, pattern string) ([]strin
 	return keys, nil
 }
 
+func (c *RedisCache) Capabilities() Capabilities {
+	return Capabilities{
+		PatternScan:      true,
+		AtomicCounters:   true,
+		NativeLists:      true,
+		TTLIntrospection: true,
+		Shared:           true,
+	}
+}
+
 func (c *RedisCache) Close() error {
 	return c.client.Close()
 }
@@ -115,3 +131,463 @@ func (c *RedisCache) ValuesByKeys(ctx context.Context, keys []string) ([]interfa
 
 	return values, nil
 }
+
+func (c *RedisCache) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	results := make([]Result, len(keys))
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		// MGET replies nil for missing keys and keys holding other types.
+		if s, ok := v.(string); ok {
+			results[i] = Result{Value: []byte(s), Found: true}
+		}
+	}
+	return results, nil
+}
+
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+func (c *RedisCache) AcquireLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (c *RedisCache) ReleaseLock(ctx context.Context, key string, token string) (bool, error) {
+	n, err := releaseLockScript.Run(ctx, c.client, []string{key}, token).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *RedisCache) ExtendLock(ctx context.Context, key string, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLockScript.Run(ctx, c.client, []string{key}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// RunScript evaluates a Lua script atomically, using EVALSHA once the script
+// is cached on the server.
+func (c *RedisCache) RunScript(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s, ok := c.scripts.Load(script)
+	if !ok {
+		s, _ = c.scripts.LoadOrStore(script, redis.NewScript(script))
+	}
+	return s.(*redis.Script).Run(ctx, c.client, keys, args...).Result()
+}
+
+// redisScanBatchSize is the SCAN COUNT hint used when deleting by pattern.
+const redisScanBatchSize = 500
+
+// DeleteByPattern scans for keys matching pattern and unlinks them page by
+// page, so memory is freed in the background and the server is never blocked.
+func (c *RedisCache) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	var deleted int64
+	it := c.ScanKeys(ctx, pattern, redisScanBatchSize)
+	for it.Next() {
+		n, err := c.client.Unlink(ctx, it.Keys()...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, it.Err()
+}
+
+// ScanKeys iterates with SCAN, batchSize being its COUNT hint.
+func (c *RedisCache) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return c.ScanKeysFrom(ctx, 0, pattern, batchSize)
+}
+
+// ScanKeysFrom resumes a scan at a SCAN cursor returned by KeyIterator.Cursor.
+func (c *RedisCache) ScanKeysFrom(ctx context.Context, cursor uint64, pattern string, batchSize int) *KeyIterator {
+	if batchSize <= 0 {
+		batchSize = DefaultScanBatchSize
+	}
+	return newKeyIterator(ctx, cursor, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		return c.client.Scan(ctx, cursor, pattern, int64(batchSize)).Result()
+	})
+}
+
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	ttl, err := c.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	switch ttl {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return NoExpiration, true, nil
+	}
+	return ttl, true, nil
+}
+
+func (c *RedisCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		deleted, err := c.client.Del(ctx, key).Result()
+		return deleted > 0, err
+	}
+	return c.client.PExpire(ctx, key, ttl).Result()
+}
+
+// Persist checks existence in the same transaction, as PERSIST alone can't
+// tell a missing key from one without a TTL.
+func (c *RedisCache) Persist(ctx context.Context, key string) (bool, error) {
+	var exists *redis.IntCmd
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Persist(ctx, key)
+		exists = pipe.Exists(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return exists.Val() > 0, nil
+}
+
+func (c *RedisCache) GetAndTouch(ctx context.Context, key string, ttl time.Duration) ([]byte, bool, error) {
+	if ttl < 0 {
+		// GETEX without an option leaves the TTL alone; 0 makes it PERSIST.
+		ttl = 0
+	}
+	value, err := c.client.GetEx(ctx, key, ttl).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// redisTTL maps a non-positive exp to 0, which go-redis sends as no expiry;
+// negative values would mean KEEPTTL.
+func redisTTL(exp time.Duration) time.Duration {
+	if exp < 0 {
+		return 0
+	}
+	return exp
+}
+
+func (c *RedisCache) StoreIfAbsent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, redisTTL(exp)).Result()
+}
+
+func (c *RedisCache) StoreIfPresent(ctx context.Context, key string, value []byte, exp time.Duration) (bool, error) {
+	return c.client.SetXX(ctx, key, value, redisTTL(exp)).Result()
+}
+
+func (c *RedisCache) Swap(ctx context.Context, key string, value []byte, exp time.Duration) ([]byte, bool, error) {
+	old, err := c.client.SetArgs(ctx, key, value, redis.SetArgs{Get: true, TTL: redisTTL(exp)}).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return old, true, nil
+}
+
+func (c *RedisCache) GetWithVersion(ctx context.Context, key string) ([]byte, string, bool, error) {
+	value, found, err := c.Get(ctx, key)
+	if err != nil || !found {
+		return nil, "", false, err
+	}
+	return value, ValueVersion(value), true, nil
+}
+
+// compareAndSwapScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] ms, or
+// none if 0, when the SHA-1 of its value is ARGV[1].
+var compareAndSwapScript = redis.NewScript(`
+local value = redis.call("GET", KEYS[1])
+if not value or redis.sha1hex(value) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1`)
+
+func (c *RedisCache) CompareAndSwap(ctx context.Context, key string, version string, value []byte, exp time.Duration) (bool, error) {
+	n, err := compareAndSwapScript.Run(ctx, c.client, []string{key}, version, value, redisTTL(exp).Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// redisPipelineBatchSize caps the commands StoreMany and DeleteMany send in
+// one round trip, so huge batches don't hold a connection for long.
+const redisPipelineBatchSize = 1000
+
+func (c *RedisCache) StoreMany(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	keys := sortedKeys(items)
+	failed := batchErrors{}
+	for start := 0; start < len(keys); start += redisPipelineBatchSize {
+		batch := keys[start:min(start+redisPipelineBatchSize, len(keys))]
+		// Each command carries its own error, so the pipeline's is not needed.
+		cmds, _ := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range batch {
+				pipe.Set(ctx, key, items[key], redisTTL(ttl))
+			}
+			return nil
+		})
+		for i, cmd := range cmds {
+			if err := cmd.Err(); err != nil {
+				failed[batch[i]] = err
+			}
+		}
+	}
+	return failed.err()
+}
+
+func (c *RedisCache) DeleteMany(ctx context.Context, keys []string) error {
+	failed := batchErrors{}
+	for start := 0; start < len(keys); start += redisPipelineBatchSize {
+		batch := keys[start:min(start+redisPipelineBatchSize, len(keys))]
+		if err := c.client.Del(ctx, batch...).Err(); err != nil {
+			for _, key := range batch {
+				failed[key] = err
+			}
+		}
+	}
+	return failed.err()
+}
+
+// redisError wraps WRONGTYPE replies, possibly raised from a script, in
+// ErrWrongType.
+func redisError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "WRONGTYPE") {
+		return fmt.Errorf("redis: %v: %w", err, ErrWrongType)
+	}
+	return err
+}
+
+// The hash scripts set a TTL of ARGV[1] ms on KEYS[1], if positive, only
+// once the write succeeded: in a MULTI a failed HSET wouldn't stop PEXPIRE.
+var (
+	hashSetScript = redis.NewScript(`
+local added = 0
+for i = 2, #ARGV, 2 do
+	added = added + redis.call("HSET", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+if tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return added`)
+
+	hashIncrByScript = redis.NewScript(`
+local value = redis.call("HINCRBY", KEYS[1], ARGV[2], ARGV[3])
+if tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return value`)
+)
+
+func (c *RedisCache) HSet(ctx context.Context, key string, fields map[string][]byte, ttl time.Duration) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, 0, 1+2*len(fields))
+	args = append(args, redisTTL(ttl).Milliseconds())
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	added, err := hashSetScript.Run(ctx, c.client, []string{key}, args...).Int64()
+	return added, redisError(err)
+}
+
+func (c *RedisCache) HGet(ctx context.Context, key string, field string) ([]byte, bool, error) {
+	value, err := c.client.HGet(ctx, key, field).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, redisError(err)
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) HGetAll(ctx context.Context, key string) (map[string][]byte, error) {
+	values, err := c.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+
+	fields := make(map[string][]byte, len(values))
+	for field, value := range values {
+		fields[field] = []byte(value)
+	}
+	return fields, nil
+}
+
+func (c *RedisCache) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.HDel(ctx, key, fields...).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) HIncrBy(ctx context.Context, key string, field string, incr int64, ttl time.Duration) (int64, error) {
+	value, err := hashIncrByScript.Run(ctx, c.client, []string{key}, redisTTL(ttl).Milliseconds(), field, incr).Int64()
+	return value, redisError(err)
+}
+
+func (c *RedisCache) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.SAdd(ctx, key, redisMembers(members)...).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.SRem(ctx, key, redisMembers(members)...).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := c.client.SMembers(ctx, key).Result()
+	return members, redisError(err)
+}
+
+func (c *RedisCache) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	ok, err := c.client.SIsMember(ctx, key, member).Result()
+	return ok, redisError(err)
+}
+
+func (c *RedisCache) SCard(ctx context.Context, key string) (int64, error) {
+	n, err := c.client.SCard(ctx, key).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) SInter(ctx context.Context, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	members, err := c.client.SInter(ctx, keys...).Result()
+	return members, redisError(err)
+}
+
+func (c *RedisCache) SUnion(ctx context.Context, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	members, err := c.client.SUnion(ctx, keys...).Result()
+	return members, redisError(err)
+}
+
+func (c *RedisCache) SDiff(ctx context.Context, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	members, err := c.client.SDiff(ctx, keys...).Result()
+	return members, redisError(err)
+}
+
+func redisMembers(members []string) []interface{} {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return args
+}
+
+// redisScore formats a score bound for ZRANGEBYSCORE and friends.
+func redisScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "+inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
+
+func (c *RedisCache) ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	zs := make([]redis.Z, len(members))
+	for i, z := range members {
+		zs[i] = redis.Z{Score: z.Score, Member: z.Member}
+	}
+	n, err := c.client.ZAdd(ctx, key, zs...).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) ZIncrBy(ctx context.Context, key string, member string, incr float64) (float64, error) {
+	score, err := c.client.ZIncrBy(ctx, key, incr, member).Result()
+	return score, redisError(err)
+}
+
+func (c *RedisCache) ZRangeByScore(ctx context.Context, key string, min, max float64) ([]string, error) {
+	members, err := c.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: redisScore(min), Max: redisScore(max)}).Result()
+	return members, redisError(err)
+}
+
+func (c *RedisCache) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) ([]ZMember, error) {
+	zs, err := c.client.ZRevRangeWithScores(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+
+	members := make([]ZMember, len(zs))
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		members[i] = ZMember{Member: member, Score: z.Score}
+	}
+	return members, nil
+}
+
+func (c *RedisCache) ZRank(ctx context.Context, key string, member string) (int64, bool, error) {
+	rank, err := c.client.ZRank(ctx, key, member).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, redisError(err)
+	}
+	return rank, true, nil
+}
+
+func (c *RedisCache) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	if len(members) == 0 {
+		return 0, nil
+	}
+	n, err := c.client.ZRem(ctx, key, redisMembers(members)...).Result()
+	return n, redisError(err)
+}
+
+func (c *RedisCache) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error) {
+	n, err := c.client.ZRemRangeByScore(ctx, key, redisScore(min), redisScore(max)).Result()
+	return n, redisError(err)
+}
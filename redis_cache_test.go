@@ -2,6 +2,7 @@ package cache_go
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -166,3 +167,107 @@ func TestRedisCache_KeysByPattern(t *testing.T) {
 		}
 	}
 }
+
+func TestRedisCache_Locker(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testLocker(t, cache)
+}
+
+func TestRedisCache_TTL(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testTTLStore(t, cache, cache, true)
+}
+
+func TestRedisCache_ConditionalStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testConditionalStore(t, cache, cache)
+}
+
+func TestRedisCache_BatchStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testBatchStore(t, cache, cache)
+}
+
+func TestRedisCache_GetMany(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testGetMany(t, cache)
+}
+
+func TestRedisCache_ScanKeys(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testScanKeys(t, cache)
+
+	// A scan resumes from the SCAN cursor of an earlier page.
+	ctx := context.Background()
+	defer cache.DeleteByPattern(ctx, "resume:*")
+	for i := 0; i < 100; i++ {
+		cache.Store(ctx, fmt.Sprintf("resume:%d", i), []byte("v"), time.Minute)
+	}
+	seen := map[string]bool{}
+	it := cache.ScanKeys(ctx, "resume:*", 10)
+	if !it.Next() {
+		t.Fatalf("Expected a first page, err: %v", it.Err())
+	}
+	for _, key := range it.Keys() {
+		seen[key] = true
+	}
+	it = cache.ScanKeysFrom(ctx, it.Cursor(), "resume:*", 10)
+	for it.Next() {
+		for _, key := range it.Keys() {
+			seen[key] = true
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Failed to resume scan: %v", err)
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 keys after resuming, got %d", len(seen))
+	}
+}
+
+func TestRedisCache_MatchPattern(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testMatchPattern(t, cache)
+}
+
+func TestRedisCache_HashStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testHashStore(t, cache, cache, cache)
+}
+
+func TestRedisCache_SetStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testSetStore(t, cache, cache)
+}
+
+func TestRedisCache_SortedSetStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testSortedSetStore(t, cache, cache)
+}
+
+func TestRedisCache_Namespaced(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cleanupTestRedis(t, cache)
+
+	testNamespaced(t, cache)
+}
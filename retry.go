@@ -0,0 +1,238 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTransientPrefixes are Redis error replies sent while a failover or
+// resharding is in progress.
+var redisTransientPrefixes = []string{"LOADING", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN"}
+
+// IsTransientError reports whether err is a network or failover error that
+// may go away on retry. Context errors are never transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var connectTimeout *memcache.ConnectTimeoutError
+	if errors.As(err, &connectTimeout) {
+		return true
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range redisTransientPrefixes {
+			if strings.HasPrefix(redisErr.Error(), prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type RetryOptions struct {
+	// MaxAttempts counts the first call too, 3 if zero.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on every retry. 50ms if zero.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts, 1s if zero.
+	MaxDelay time.Duration
+	// IsRetryable decides which errors are retried, IsTransientError if nil.
+	IsRetryable func(err error) bool
+}
+
+// RetryingRepo is a CacheRepo decorator retrying idempotent operations on
+// transient errors, with exponential backoff and jitter. Increment,
+// IncrementWithTTL, LPush, LTrim and LRem are never retried: a request that
+// failed on the way back may already have been applied.
+type RetryingRepo struct {
+	repo        CacheRepo
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	isRetryable func(err error) bool
+}
+
+func NewRetryingRepo(repo CacheRepo, opts RetryOptions) *RetryingRepo {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 50 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = time.Second
+	}
+	if opts.IsRetryable == nil {
+		opts.IsRetryable = IsTransientError
+	}
+
+	return &RetryingRepo{
+		repo:        repo,
+		maxAttempts: opts.MaxAttempts,
+		baseDelay:   opts.BaseDelay,
+		maxDelay:    opts.MaxDelay,
+		isRetryable: opts.IsRetryable,
+	}
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error, runs
+// out of attempts, or the next delay would not fit before the ctx deadline.
+func (r *RetryingRepo) retry(ctx context.Context, fn func() error) error {
+	delay := r.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.maxAttempts || !r.isRetryable(err) {
+			return err
+		}
+
+		// Equal jitter: at least half the backoff, so retries still spread out.
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > r.maxDelay {
+			delay = r.maxDelay
+		}
+	}
+}
+
+func (r *RetryingRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	return r.retry(ctx, func() error {
+		return r.repo.Store(ctx, key, value, exp)
+	})
+}
+
+func (r *RetryingRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	return r.retry(ctx, func() error {
+		return r.repo.StoreWithoutTTL(ctx, key, value)
+	})
+}
+
+func (r *RetryingRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var (
+		value []byte
+		found bool
+	)
+	err := r.retry(ctx, func() (err error) {
+		value, found, err = r.repo.Get(ctx, key)
+		return err
+	})
+	return value, found, err
+}
+
+func (r *RetryingRepo) Delete(ctx context.Context, key string) error {
+	return r.retry(ctx, func() error {
+		return r.repo.Delete(ctx, key)
+	})
+}
+
+func (r *RetryingRepo) Increment(ctx context.Context, key string) (int64, error) {
+	return r.repo.Increment(ctx, key)
+}
+
+func (r *RetryingRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	return r.repo.IncrementWithTTL(ctx, key, exp)
+}
+
+func (r *RetryingRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return r.repo.LPush(ctx, key, value)
+}
+
+func (r *RetryingRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	var values []string
+	err := r.retry(ctx, func() (err error) {
+		values, err = r.repo.LRange(ctx, key, start, end)
+		return err
+	})
+	return values, err
+}
+
+// LTrim is not retried: trimming by a relative range twice, such as 1 -1,
+// removes more elements.
+func (r *RetryingRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return r.repo.LTrim(ctx, key, start, end)
+}
+
+func (r *RetryingRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return r.repo.LRem(ctx, key, count, value)
+}
+
+func (r *RetryingRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	err := r.retry(ctx, func() (err error) {
+		keys, err = r.repo.KeysByPattern(ctx, pattern)
+		return err
+	})
+	return keys, err
+}
+
+// ScanKeys is not retried, as a page can't be fetched again once the
+// iterator moved past it.
+func (r *RetryingRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return ScanKeys(ctx, r.repo, pattern, batchSize)
+}
+
+func (r *RetryingRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	var values []interface{}
+	err := r.retry(ctx, func() (err error) {
+		values, err = r.repo.ValuesByKeys(ctx, keys)
+		return err
+	})
+	return values, err
+}
+
+func (r *RetryingRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	var results []Result
+	err := r.retry(ctx, func() (err error) {
+		results, err = GetMany(ctx, r.repo, keys)
+		return err
+	})
+	return results, err
+}
+
+func (r *RetryingRepo) Unwrap() CacheRepo {
+	return r.repo
+}
+
+func (r *RetryingRepo) Close() error {
+	return r.repo.Close()
+}
+
+func (r *RetryingRepo) Ping(ctx context.Context) error {
+	return r.retry(ctx, func() error {
+		return r.repo.Ping(ctx)
+	})
+}
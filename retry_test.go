@@ -0,0 +1,101 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type redisReplyError string
+
+func (e redisReplyError) Error() string { return string(e) }
+func (e redisReplyError) RedisError()   {}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("WRONGTYPE Operation against a key holding the wrong kind of value"), want: false},
+		{err: context.Canceled, want: false},
+		{err: context.DeadlineExceeded, want: false},
+		{err: io.EOF, want: true},
+		{err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{err: redisReplyError("READONLY You can't write against a read only replica."), want: true},
+		{err: redisReplyError("LOADING Redis is loading the dataset in memory"), want: true},
+		{err: redisReplyError("ERR unknown command"), want: false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsTransientError(tt.err), "%v", tt.err)
+	}
+}
+
+func TestRetryingRepo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	repo := NewRetryingRepo(mockRepo, RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	t.Run("Get retries until success", func(t *testing.T) {
+		gomock.InOrder(
+			mockRepo.EXPECT().Get(ctx, "key").Return(nil, false, io.EOF),
+			mockRepo.EXPECT().Get(ctx, "key").Return([]byte("value"), true, nil),
+		)
+		value, found, err := repo.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "value", string(value))
+	})
+
+	t.Run("Store gives up after max attempts", func(t *testing.T) {
+		mockRepo.EXPECT().Store(ctx, "key", []byte("value"), time.Minute).Return(io.EOF).Times(3)
+		assert.Equal(t, io.EOF, repo.Store(ctx, "key", []byte("value"), time.Minute))
+	})
+
+	t.Run("Permanent errors are not retried", func(t *testing.T) {
+		permanent := errors.New("permanent")
+		mockRepo.EXPECT().Delete(ctx, "key").Return(permanent).Times(1)
+		assert.Equal(t, permanent, repo.Delete(ctx, "key"))
+	})
+
+	t.Run("Non-idempotent operations are not retried", func(t *testing.T) {
+		mockRepo.EXPECT().Increment(ctx, "key").Return(int64(0), io.EOF).Times(1)
+		mockRepo.EXPECT().LPush(ctx, "key", []byte("value")).Return(io.EOF).Times(1)
+		mockRepo.EXPECT().LTrim(ctx, "key", int64(1), int64(-1)).Return(io.EOF).Times(1)
+		_, err := repo.Increment(ctx, "key")
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, io.EOF, repo.LPush(ctx, "key", []byte("value")))
+		assert.Equal(t, io.EOF, repo.LTrim(ctx, "key", 1, -1))
+	})
+}
+
+func TestRetryingRepo_Deadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	mockRepo := mocks.NewMockCacheRepo(ctrl)
+	repo := NewRetryingRepo(mockRepo, RetryOptions{MaxAttempts: 10, BaseDelay: time.Second})
+
+	// The first backoff doesn't fit before the deadline, so there is no retry
+	mockRepo.EXPECT().Get(ctx, "key").Return(nil, false, io.EOF).Times(1)
+	start := time.Now()
+	_, _, err := repo.Get(ctx, "key")
+	assert.Equal(t, io.EOF, err)
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+}
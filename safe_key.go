@@ -0,0 +1,151 @@
+package cache_go
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// MaxMemcacheKeyLength is the longest key memcached accepts.
+	MaxMemcacheKeyLength = 250
+
+	// safeKeyReadableLength bounds the readable part kept in front of a hashed key.
+	safeKeyReadableLength = 128
+)
+
+// IsValidMemcacheKey reports whether key can be stored by memcached as-is:
+// not empty, at most 250 bytes and free of whitespace and control characters.
+func IsValidMemcacheKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxMemcacheKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isValidMemcacheKeyByte(key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidMemcacheKeyByte(c byte) bool {
+	return c > ' ' && c != 0x7f
+}
+
+// SafeKey returns key unchanged when it is a valid memcache key. Otherwise it
+// returns a stable hashed form "<readable prefix>#<sha256 hex>", where the
+// readable prefix is the beginning of key with invalid bytes replaced by '_'.
+func SafeKey(key string) string {
+	if IsValidMemcacheKey(key) {
+		return key
+	}
+
+	readable := make([]byte, 0, safeKeyReadableLength)
+	for i := 0; i < len(key) && len(readable) < safeKeyReadableLength; i++ {
+		c := key[i]
+		if !isValidMemcacheKeyByte(c) {
+			c = '_'
+		}
+		readable = append(readable, c)
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return string(readable) + "#" + hex.EncodeToString(sum[:])
+}
+
+// SafeKeyRepo is a CacheRepo decorator that maps every key through SafeKey
+// before handing it to the wrapped repo, so arbitrary ids can be used as
+// keys on backends with strict key rules such as MemcacheRepo.
+type SafeKeyRepo struct {
+	repo CacheRepo
+}
+
+func NewSafeKeyRepo(repo CacheRepo) *SafeKeyRepo {
+	return &SafeKeyRepo{
+		repo: repo,
+	}
+}
+
+func (s *SafeKeyRepo) key(key string) string {
+	return SafeKey(key)
+}
+
+func (s *SafeKeyRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	return s.repo.Store(ctx, SafeKey(key), value, exp)
+}
+
+func (s *SafeKeyRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	return s.repo.StoreWithoutTTL(ctx, SafeKey(key), value)
+}
+
+func (s *SafeKeyRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.repo.Get(ctx, SafeKey(key))
+}
+
+func (s *SafeKeyRepo) Delete(ctx context.Context, key string) error {
+	return s.repo.Delete(ctx, SafeKey(key))
+}
+
+func (s *SafeKeyRepo) Increment(ctx context.Context, key string) (int64, error) {
+	return s.repo.Increment(ctx, SafeKey(key))
+}
+
+func (s *SafeKeyRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	return s.repo.IncrementWithTTL(ctx, SafeKey(key), exp)
+}
+
+func (s *SafeKeyRepo) LPush(ctx context.Context, key string, value []byte) error {
+	return s.repo.LPush(ctx, SafeKey(key), value)
+}
+
+func (s *SafeKeyRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	return s.repo.LRange(ctx, SafeKey(key), start, end)
+}
+
+func (s *SafeKeyRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	return s.repo.LTrim(ctx, SafeKey(key), start, end)
+}
+
+func (s *SafeKeyRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	return s.repo.LRem(ctx, SafeKey(key), count, value)
+}
+
+// KeysByPattern passes pattern through unchanged and returns keys as they are
+// stored, so hashed keys come back in their hashed form.
+func (s *SafeKeyRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	return s.repo.KeysByPattern(ctx, pattern)
+}
+
+// ScanKeys returns keys as they are stored, like KeysByPattern.
+func (s *SafeKeyRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	return ScanKeys(ctx, s.repo, pattern, batchSize)
+}
+
+func (s *SafeKeyRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	safeKeys := make([]string, len(keys))
+	for i, k := range keys {
+		safeKeys[i] = SafeKey(k)
+	}
+	return s.repo.ValuesByKeys(ctx, safeKeys)
+}
+
+func (s *SafeKeyRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	safeKeys := make([]string, len(keys))
+	for i, k := range keys {
+		safeKeys[i] = SafeKey(k)
+	}
+	return GetMany(ctx, s.repo, safeKeys)
+}
+
+func (s *SafeKeyRepo) Unwrap() CacheRepo {
+	return s.repo
+}
+
+func (s *SafeKeyRepo) Close() error {
+	return s.repo.Close()
+}
+
+func (s *SafeKeyRepo) Ping(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
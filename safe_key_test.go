@@ -0,0 +1,71 @@
+package cache_go
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeKey(t *testing.T) {
+	long := strings.Repeat("a", MaxMemcacheKeyLength+1)
+
+	tests := []struct {
+		name      string
+		key       string
+		unchanged bool
+	}{
+		{name: "valid key", key: "user:42", unchanged: true},
+		{name: "max length", key: strings.Repeat("a", MaxMemcacheKeyLength), unchanged: true},
+		{name: "too long", key: long},
+		{name: "whitespace", key: "user:john doe"},
+		{name: "newline", key: "user:42\r\n"},
+		{name: "control character", key: "user:\x00"},
+		{name: "empty", key: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SafeKey(tt.key)
+			if tt.unchanged {
+				assert.Equal(t, tt.key, got)
+				return
+			}
+			assert.NotEqual(t, tt.key, got)
+			assert.True(t, IsValidMemcacheKey(got), "hashed key %q should be valid", got)
+			assert.Equal(t, got, SafeKey(tt.key), "hashing should be stable")
+		})
+	}
+
+	assert.True(t, strings.HasPrefix(SafeKey("user:john doe"), "user:john_doe#"))
+	assert.NotEqual(t, SafeKey(long), SafeKey(long+"b"))
+}
+
+func TestSafeKeyRepo(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryCache()
+	repo := NewSafeKeyRepo(inner)
+	key := "report:" + strings.Repeat("x", 300) + " with spaces"
+
+	assert.NoError(t, repo.Store(ctx, key, []byte("value"), time.Minute))
+
+	got, found, err := repo.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", string(got))
+
+	_, found, _ = inner.Get(ctx, key)
+	assert.False(t, found, "raw key should not reach the wrapped repo")
+	_, found, _ = inner.Get(ctx, SafeKey(key))
+	assert.True(t, found)
+
+	values, err := repo.ValuesByKeys(ctx, []string{key, "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("value"), nil}, values)
+
+	assert.NoError(t, repo.Delete(ctx, key))
+	_, found, _ = repo.Get(ctx, key)
+	assert.False(t, found)
+}
@@ -0,0 +1,146 @@
+package cache_go
+
+import "context"
+
+// DefaultScanBatchSize is the page size ScanKeys uses when given a
+// non-positive batch size.
+const DefaultScanBatchSize = 100
+
+// KeyScanner is implemented by backends that can list keys page by page
+// instead of loading every match at once.
+type KeyScanner interface {
+	// ScanKeys iterates over the keys matching pattern, about batchSize keys
+	// per page. A key may be returned twice, and keys written during the scan
+	// may be missed.
+	ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator
+}
+
+// scanPageFunc fetches the page of keys at cursor and returns the cursor of
+// the next page, 0 once the scan is complete.
+type scanPageFunc func(ctx context.Context, cursor uint64) ([]string, uint64, error)
+
+// KeyIterator walks over pages of keys:
+//
+//	it := cache_go.ScanKeys(ctx, repo, "session:*", 1000)
+//	for it.Next() {
+//		process(it.Keys())
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type KeyIterator struct {
+	ctx    context.Context
+	page   scanPageFunc
+	cursor uint64
+	keys   []string
+	done   bool
+	err    error
+}
+
+func newKeyIterator(ctx context.Context, cursor uint64, page scanPageFunc) *KeyIterator {
+	return &KeyIterator{ctx: ctx, cursor: cursor, page: page}
+}
+
+// Next fetches the next non-empty page and reports whether there is one.
+func (it *KeyIterator) Next() bool {
+	for !it.done {
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			it.done = true
+			break
+		}
+
+		keys, next, err := it.page(it.ctx, it.cursor)
+		if err != nil {
+			it.err = err
+			it.done = true
+			break
+		}
+
+		it.cursor = next
+		it.done = next == 0
+		if len(keys) > 0 {
+			it.keys = keys
+			return true
+		}
+	}
+
+	it.keys = nil
+	return false
+}
+
+// Keys returns the current page.
+func (it *KeyIterator) Keys() []string {
+	return it.keys
+}
+
+// Cursor returns the position after the current page, 0 once the scan is
+// complete. With RedisCache it is the SCAN cursor, which ScanKeysFrom can
+// resume from.
+func (it *KeyIterator) Cursor() uint64 {
+	return it.cursor
+}
+
+func (it *KeyIterator) Err() error {
+	return it.err
+}
+
+// ScanKeys iterates over the keys of repo matching pattern, with its
+// ScanKeys when it is a KeyScanner and by paging over KeysByPattern
+// otherwise.
+func ScanKeys(ctx context.Context, repo CacheRepo, pattern string, batchSize int) *KeyIterator {
+	if scanner, ok := repo.(KeyScanner); ok {
+		return scanner.ScanKeys(ctx, pattern, batchSize)
+	}
+
+	var keys []string
+	return newKeyIterator(ctx, 0, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		if cursor == 0 {
+			var err error
+			if keys, err = repo.KeysByPattern(ctx, pattern); err != nil {
+				return nil, 0, err
+			}
+		}
+		return pageOf(keys, cursor, batchSize)
+	})
+}
+
+// mapKeys iterates over it, passing each page through fn, for decorators
+// that store keys in another form.
+func mapKeys(ctx context.Context, it *KeyIterator, fn func(keys []string) []string) *KeyIterator {
+	return newKeyIterator(ctx, 0, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		if !it.Next() {
+			return nil, 0, it.Err()
+		}
+		return fn(it.Keys()), it.Cursor(), nil
+	})
+}
+
+// wrapPages returns an iterator fetching the pages of it through wrap, for
+// decorators that gate or observe every page.
+func wrapPages(it *KeyIterator, wrap func(ctx context.Context, cursor uint64, page scanPageFunc) ([]string, uint64, error)) *KeyIterator {
+	return newKeyIterator(it.ctx, it.cursor, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		return wrap(ctx, cursor, it.page)
+	})
+}
+
+// errKeyIterator returns an iterator failing with err.
+func errKeyIterator(ctx context.Context, err error) *KeyIterator {
+	return newKeyIterator(ctx, 0, func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		return nil, 0, err
+	})
+}
+
+// pageOf returns the page of keys starting at index cursor.
+func pageOf(keys []string, cursor uint64, batchSize int) ([]string, uint64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultScanBatchSize
+	}
+
+	start := min(int(cursor), len(keys))
+	end := min(start+batchSize, len(keys))
+	if end == len(keys) {
+		return keys[start:end], 0, nil
+	}
+	return keys[start:end], uint64(end), nil
+}
@@ -0,0 +1,134 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/harryosmar/cache-go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testScanKeys runs the ScanKeys behaviour shared by every backend.
+func testScanKeys(t *testing.T, repo CacheRepo) {
+	ctx := context.Background()
+
+	var want []string
+	for i := 0; i < 250; i++ {
+		key := fmt.Sprintf("scan:%03d", i)
+		require.NoError(t, repo.Store(ctx, key, []byte("v"), time.Minute))
+		want = append(want, key)
+	}
+	require.NoError(t, repo.Store(ctx, "other", []byte("v"), time.Minute))
+	defer repo.Delete(ctx, "other")
+	for _, key := range want {
+		defer repo.Delete(ctx, key)
+	}
+
+	seen := map[string]bool{}
+	it := ScanKeys(ctx, repo, "scan:*", 50)
+	for it.Next() {
+		assert.NotEmpty(t, it.Keys())
+		for _, key := range it.Keys() {
+			seen[key] = true
+		}
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, uint64(0), it.Cursor())
+
+	got := sortedKeys(seen)
+	sort.Strings(got)
+	assert.Equal(t, want, got)
+
+	it = ScanKeys(ctx, repo, "missing:*", 0)
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
+
+func TestMemoryCache_ScanKeys(t *testing.T) {
+	testScanKeys(t, NewMemoryCache())
+}
+
+func TestMemoryCache_ScanKeysConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache()
+	for i := 0; i < 10; i++ {
+		cache.Store(ctx, fmt.Sprintf("scan:%d", i), []byte("v"), time.Minute)
+	}
+
+	it := cache.ScanKeys(ctx, "scan:*", 2)
+	require.True(t, it.Next())
+
+	// Writers aren't blocked between pages, and deleted keys are skipped.
+	for i := 0; i < 10; i++ {
+		require.NoError(t, cache.Delete(ctx, fmt.Sprintf("scan:%d", i)))
+	}
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
+
+func TestScanKeys_Fallback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	repo := mocks.NewMockCacheRepo(ctrl)
+	repo.EXPECT().KeysByPattern(ctx, "a:*").Return([]string{"a:1", "a:2", "a:3"}, nil)
+
+	it := ScanKeys(ctx, repo, "a:*", 2)
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"a:1", "a:2"}, it.Keys())
+	assert.Equal(t, uint64(2), it.Cursor())
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"a:3"}, it.Keys())
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
+
+func TestScanKeys_Errors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	repo := mocks.NewMockCacheRepo(ctrl)
+	repo.EXPECT().KeysByPattern(ctx, "a:*").Return(nil, ErrNotSupported)
+
+	it := ScanKeys(ctx, repo, "a:*", 2)
+	assert.False(t, it.Next())
+	assert.True(t, errors.Is(it.Err(), ErrNotSupported))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	it = ScanKeys(cancelled, NewMemoryCache(), "*", 0)
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+}
+
+// scanOnlyCache fails KeysByPattern, so only its ScanKeys can list keys.
+type scanOnlyCache struct {
+	*MemoryCache
+}
+
+func (s scanOnlyCache) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	return nil, errors.New("KeysByPattern called")
+}
+
+func TestScanKeys_Decorators(t *testing.T) {
+	backend := NewMemoryCache()
+	repo := Namespaced(NewRetryingRepo(scanOnlyCache{backend}, RetryOptions{}), "ns:")
+	testScanKeys(t, repo)
+
+	ctx := context.Background()
+	require.NoError(t, backend.Store(ctx, "scan:outside", []byte("v"), time.Minute))
+	require.NoError(t, repo.Store(ctx, "scan:inside", []byte("v"), time.Minute))
+	it := ScanKeys(ctx, repo, "scan:*", 0)
+	require.True(t, it.Next())
+	assert.Equal(t, []string{"scan:inside"}, it.Keys())
+	assert.False(t, it.Next())
+	assert.NoError(t, it.Err())
+}
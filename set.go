@@ -0,0 +1,69 @@
+package cache_go
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetStore is implemented by backends that store sets of strings under a
+// key, like Redis sets. Members come back in no particular order, and
+// removing the last member deletes the set.
+type SetStore interface {
+	// SAdd adds members to the set at key and returns how many were new.
+	SAdd(ctx context.Context, key string, members ...string) (int64, error)
+	// SRem removes members from the set at key and returns how many existed.
+	SRem(ctx context.Context, key string, members ...string) (int64, error)
+	SMembers(ctx context.Context, key string) ([]string, error)
+	SIsMember(ctx context.Context, key string, member string) (bool, error)
+	SCard(ctx context.Context, key string) (int64, error)
+}
+
+// SetAlgebra is implemented by SetStores that combine sets atomically on the
+// backend. Missing keys count as empty sets.
+type SetAlgebra interface {
+	// SInter returns the members of every set at keys.
+	SInter(ctx context.Context, keys ...string) ([]string, error)
+	// SUnion returns the members of any set at keys.
+	SUnion(ctx context.Context, keys ...string) ([]string, error)
+	// SDiff returns the members of the first set that are in none of the
+	// others.
+	SDiff(ctx context.Context, keys ...string) ([]string, error)
+}
+
+// SInter returns the members of every set at keys, or an error wrapping
+// ErrNotSupported if repo is not a SetAlgebra.
+func SInter(ctx context.Context, repo CacheRepo, keys ...string) ([]string, error) {
+	algebra, err := setAlgebraOf(repo, "SInter")
+	if err != nil {
+		return nil, err
+	}
+	return algebra.SInter(ctx, keys...)
+}
+
+// SUnion returns the members of any set at keys, or an error wrapping
+// ErrNotSupported if repo is not a SetAlgebra.
+func SUnion(ctx context.Context, repo CacheRepo, keys ...string) ([]string, error) {
+	algebra, err := setAlgebraOf(repo, "SUnion")
+	if err != nil {
+		return nil, err
+	}
+	return algebra.SUnion(ctx, keys...)
+}
+
+// SDiff returns the members of the first set at keys that are in none of the
+// others, or an error wrapping ErrNotSupported if repo is not a SetAlgebra.
+func SDiff(ctx context.Context, repo CacheRepo, keys ...string) ([]string, error) {
+	algebra, err := setAlgebraOf(repo, "SDiff")
+	if err != nil {
+		return nil, err
+	}
+	return algebra.SDiff(ctx, keys...)
+}
+
+func setAlgebraOf(repo CacheRepo, op string) (SetAlgebra, error) {
+	algebra, ok := repo.(SetAlgebra)
+	if !ok {
+		return nil, fmt.Errorf("cache_go: %s on %T: %w", op, repo, ErrNotSupported)
+	}
+	return algebra, nil
+}
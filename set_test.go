@@ -0,0 +1,112 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sorted(members []string) []string {
+	sort.Strings(members)
+	return members
+}
+
+// testSetStore runs the SetStore and SetAlgebra behaviour shared by every
+// backend.
+func testSetStore(t *testing.T, store SetStore, repo CacheRepo) {
+	ctx := context.Background()
+	for _, key := range []string{"set:room1", "set:room2", "set:room3", "set:string"} {
+		defer repo.Delete(ctx, key)
+	}
+
+	added, err := store.SAdd(ctx, "set:room1", "ann", "bob", "ann")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	added, err = store.SAdd(ctx, "set:room1", "bob", "cid")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+
+	members, err := store.SMembers(ctx, "set:room1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ann", "bob", "cid"}, sorted(members))
+	n, err := store.SCard(ctx, "set:room1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	ok, err := store.SIsMember(ctx, "set:room1", "bob")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = store.SIsMember(ctx, "set:room1", "dan")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	members, err = store.SMembers(ctx, "set:missing")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+	n, err = store.SCard(ctx, "set:missing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	_, err = store.SAdd(ctx, "set:room2", "bob", "cid", "dan")
+	require.NoError(t, err)
+
+	members, err = SInter(ctx, repo, "set:room1", "set:room2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob", "cid"}, sorted(members))
+	members, err = SUnion(ctx, repo, "set:room1", "set:room2", "set:missing")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ann", "bob", "cid", "dan"}, sorted(members))
+	members, err = SDiff(ctx, repo, "set:room1", "set:room2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ann"}, members)
+	members, err = SInter(ctx, repo, "set:room1", "set:missing")
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	removed, err := store.SRem(ctx, "set:room2", "bob", "eve")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	removed, err = store.SRem(ctx, "set:room2", "cid", "dan")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+	_, found, err := repo.Get(ctx, "set:room2")
+	require.NoError(t, err)
+	assert.False(t, found, "an emptied set is deleted")
+
+	require.NoError(t, repo.Store(ctx, "set:string", []byte("plain"), time.Minute))
+	_, err = store.SAdd(ctx, "set:string", "a")
+	assert.True(t, errors.Is(err, ErrWrongType), "SAdd: %v", err)
+	_, err = store.SIsMember(ctx, "set:string", "a")
+	assert.True(t, errors.Is(err, ErrWrongType), "SIsMember: %v", err)
+	_, err = SUnion(ctx, repo, "set:room1", "set:string")
+	assert.True(t, errors.Is(err, ErrWrongType), "SUnion: %v", err)
+}
+
+func TestMemoryCache_SetStore(t *testing.T) {
+	cache := NewMemoryCache()
+	testSetStore(t, cache, cache)
+
+	// Sets aren't strings or hashes.
+	ctx := context.Background()
+	cache.SAdd(ctx, "set", "a")
+	_, _, err := cache.Get(ctx, "set")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = cache.HGetAll(ctx, "set")
+	assert.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestSetAlgebra_NotSupported(t *testing.T) {
+	ctx := context.Background()
+	for _, repo := range []CacheRepo{NewMemcacheRepo("localhost:11211"), NewNocacheRepo()} {
+		_, err := SInter(ctx, repo, "a", "b")
+		assert.ErrorIs(t, err, ErrNotSupported)
+		_, err = SUnion(ctx, repo, "a", "b")
+		assert.ErrorIs(t, err, ErrNotSupported)
+		_, err = SDiff(ctx, repo, "a", "b")
+		assert.ErrorIs(t, err, ErrNotSupported)
+	}
+}
@@ -0,0 +1,263 @@
+package cache_go
+
+import (
+	"math/rand"
+)
+
+const (
+	skipListMaxLevel = 32
+	// skipListP is the chance a node reaches the next level, as in Redis.
+	skipListP = 0.25
+)
+
+// sortedSet is MemoryCache's sorted set: a map from member to score, and a
+// skip list ordering members by score then member, like Redis' zset. Each
+// link records how many nodes it spans, which makes ranks O(log n).
+type sortedSet struct {
+	scores map[string]float64
+	head   *skipListNode
+	tail   *skipListNode
+	level  int
+}
+
+type skipListNode struct {
+	member   string
+	score    float64
+	backward *skipListNode
+	levels   []skipListLevel
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		scores: make(map[string]float64),
+		head:   &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level:  1,
+	}
+}
+
+func (s *sortedSet) len() int {
+	return len(s.scores)
+}
+
+// before reports whether n sorts before the member with score.
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// after reports whether n sorts after the member with score.
+func (n *skipListNode) after(score float64, member string) bool {
+	return n.score > score || (n.score == score && n.member > member)
+}
+
+func randomSkipListLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// add sets the score of member and reports whether it is new.
+func (s *sortedSet) add(member string, score float64) bool {
+	current, exists := s.scores[member]
+	if exists {
+		if current == score {
+			return false
+		}
+		s.remove(member)
+	}
+
+	var (
+		update [skipListMaxLevel]*skipListNode
+		rank   [skipListMaxLevel]int
+	)
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		if i < s.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomSkipListLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+			update[i].levels[i].span = s.len()
+		}
+		s.level = level
+	}
+
+	n := &skipListNode{member: member, score: score, levels: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		n.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = n
+		n.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < s.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != s.head {
+		n.backward = update[0]
+	}
+	if n.levels[0].forward != nil {
+		n.levels[0].forward.backward = n
+	} else {
+		s.tail = n
+	}
+	s.scores[member] = score
+	return !exists
+}
+
+// remove deletes member and reports whether it existed.
+func (s *sortedSet) remove(member string) bool {
+	score, exists := s.scores[member]
+	if !exists {
+		return false
+	}
+
+	var update [skipListMaxLevel]*skipListNode
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	s.unlink(x.levels[0].forward, &update)
+	return true
+}
+
+// unlink removes n, given the last node before it on each level.
+func (s *sortedSet) unlink(n *skipListNode, update *[skipListMaxLevel]*skipListNode) {
+	for i := 0; i < s.level; i++ {
+		if update[i].levels[i].forward == n {
+			update[i].levels[i].span += n.levels[i].span - 1
+			update[i].levels[i].forward = n.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if n.levels[0].forward != nil {
+		n.levels[0].forward.backward = n.backward
+	} else {
+		s.tail = n.backward
+	}
+	for s.level > 1 && s.head.levels[s.level-1].forward == nil {
+		s.level--
+	}
+	delete(s.scores, n.member)
+}
+
+// rank returns the 0-based rank of member from the lowest score.
+func (s *sortedSet) rank(member string) (int, bool) {
+	score, exists := s.scores[member]
+	if !exists {
+		return 0, false
+	}
+
+	rank := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !x.levels[i].forward.after(score, member) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != s.head && x.member == member {
+			return rank - 1, true
+		}
+	}
+	return 0, false
+}
+
+// byRank returns the node at the 0-based rank, nil if out of range.
+func (s *sortedSet) byRank(rank int) *skipListNode {
+	traversed := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstInRange returns the lowest node scored min or more, filling update
+// with the last node before it on each level if not nil.
+func (s *sortedSet) firstInRange(min float64, update *[skipListMaxLevel]*skipListNode) *skipListNode {
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.score < min {
+			x = x.levels[i].forward
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x.levels[0].forward
+}
+
+func (s *sortedSet) rangeByScore(min, max float64) []string {
+	members := []string{}
+	for x := s.firstInRange(min, nil); x != nil && x.score <= max; x = x.levels[0].forward {
+		members = append(members, x.member)
+	}
+	return members
+}
+
+func (s *sortedSet) removeRangeByScore(min, max float64) int {
+	var update [skipListMaxLevel]*skipListNode
+	removed := 0
+	x := s.firstInRange(min, &update)
+	for x != nil && x.score <= max {
+		next := x.levels[0].forward
+		s.unlink(x, &update)
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// revRange returns the members ranked start to stop from the highest score,
+// with Redis' handling of negative and out of range indexes.
+func (s *sortedSet) revRange(start, stop int64) []ZMember {
+	length := int64(s.len())
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []ZMember{}
+	}
+
+	members := make([]ZMember, 0, stop-start+1)
+	x := s.byRank(int(length - 1 - start))
+	for i := start; i <= stop; i++ {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+		x = x.backward
+	}
+	return members
+}
@@ -0,0 +1,90 @@
+package cache_go
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSortedSet_MatchesSortedSlice checks the skip list against a sorted
+// slice through random adds, updates and removals.
+func TestSortedSet_MatchesSortedSlice(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := newSortedSet()
+	scores := map[string]float64{}
+
+	for i := 0; i < 5000; i++ {
+		member := fmt.Sprintf("m%d", rng.Intn(300))
+		switch op := rng.Intn(10); {
+		case op < 6:
+			// Few distinct scores, so ties are ordered by member.
+			score := float64(rng.Intn(50))
+			_, exists := scores[member]
+			assert.Equal(t, !exists, s.add(member, score))
+			scores[member] = score
+		case op < 9:
+			_, exists := scores[member]
+			assert.Equal(t, exists, s.remove(member))
+			delete(scores, member)
+		default:
+			min := float64(rng.Intn(50))
+			max := min + float64(rng.Intn(5))
+			removed := 0
+			for m, score := range scores {
+				if score >= min && score <= max {
+					delete(scores, m)
+					removed++
+				}
+			}
+			assert.Equal(t, removed, s.removeRangeByScore(min, max))
+		}
+	}
+
+	want := make([]ZMember, 0, len(scores))
+	for member, score := range scores {
+		want = append(want, ZMember{Member: member, Score: score})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		return want[i].Score < want[j].Score || (want[i].Score == want[j].Score && want[i].Member < want[j].Member)
+	})
+	require.Equal(t, len(want), s.len())
+
+	for i, z := range want {
+		rank, ok := s.rank(z.Member)
+		require.True(t, ok)
+		require.Equal(t, i, rank, z.Member)
+		require.Equal(t, z.Member, s.byRank(i).member)
+	}
+
+	reversed := s.revRange(0, -1)
+	require.Len(t, reversed, len(want))
+	for i, z := range reversed {
+		assert.Equal(t, want[len(want)-1-i], z)
+	}
+
+	var inRange []string
+	for _, z := range want {
+		if z.Score >= 10 && z.Score <= 20 {
+			inRange = append(inRange, z.Member)
+		}
+	}
+	assert.Equal(t, inRange, s.rangeByScore(10, 20))
+}
+
+func TestSortedSet_RevRangeIndexes(t *testing.T) {
+	s := newSortedSet()
+	for i, member := range []string{"a", "b", "c", "d"} {
+		s.add(member, float64(i))
+	}
+
+	assert.Equal(t, []ZMember{{"d", 3}, {"c", 2}}, s.revRange(0, 1))
+	assert.Equal(t, []ZMember{{"b", 1}, {"a", 0}}, s.revRange(-2, -1))
+	assert.Equal(t, []ZMember{{"a", 0}}, s.revRange(3, 10))
+	assert.Empty(t, s.revRange(4, 5))
+	assert.Empty(t, s.revRange(2, 1))
+	assert.Nil(t, s.byRank(4))
+}
@@ -0,0 +1,238 @@
+package cache_go
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/harryosmar/cache-go"
+
+// CallTracer is implemented by decorators that want GetFromCache and
+// GetFromCacheWithDynamicTTL to open a span around the whole call and around
+// the fnCacheable call. The returned func ends the span.
+type CallTracer interface {
+	StartCall(ctx context.Context, name string, key string) (context.Context, func(err error))
+}
+
+type TracingOptions struct {
+	// TracerProvider creates the tracer, the global provider if nil.
+	TracerProvider trace.TracerProvider
+	// System is reported as db.system, e.g. "redis" or "memcached".
+	System string
+	// HashKeys records a sha256 of the key instead of the key itself, for
+	// keys that contain identifiers which must not leave the service.
+	HashKeys bool
+}
+
+// TracingRepo is a CacheRepo decorator opening an OpenTelemetry span per
+// call. GetFromCache finds it as a CallTracer under other decorators too, and
+// records keys as the TracingRepo receives them: hashed below an
+// EncryptingRepo with key hashing.
+type TracingRepo struct {
+	repo     CacheRepo
+	tracer   trace.Tracer
+	system   string
+	hashKeys bool
+}
+
+func NewTracingRepo(repo CacheRepo, opts TracingOptions) *TracingRepo {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+
+	return &TracingRepo{
+		repo:     repo,
+		tracer:   opts.TracerProvider.Tracer(tracerName),
+		system:   opts.System,
+		hashKeys: opts.HashKeys,
+	}
+}
+
+func (t *TracingRepo) keyAttribute(key string) attribute.KeyValue {
+	if t.hashKeys {
+		sum := sha256.Sum256([]byte(key))
+		return attribute.String("cache.key_hash", hex.EncodeToString(sum[:]))
+	}
+	return attribute.String("cache.key", key)
+}
+
+func (t *TracingRepo) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("db.system", t.system),
+		attribute.String("db.operation", operation),
+	)
+	return t.tracer.Start(ctx, "cache."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *TracingRepo) StartCall(ctx context.Context, name string, key string) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("db.system", t.system),
+		t.keyAttribute(key),
+	))
+	return ctx, func(err error) {
+		endSpan(span, err)
+	}
+}
+
+func (t *TracingRepo) Store(ctx context.Context, key string, value []byte, exp time.Duration) error {
+	ctx, span := t.start(ctx, "Store", t.keyAttribute(key))
+	err := t.repo.Store(ctx, key, value, exp)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) StoreWithoutTTL(ctx context.Context, key string, value []byte) error {
+	ctx, span := t.start(ctx, "StoreWithoutTTL", t.keyAttribute(key))
+	err := t.repo.StoreWithoutTTL(ctx, key, value)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ctx, span := t.start(ctx, "Get", t.keyAttribute(key))
+	value, found, err := t.repo.Get(ctx, key)
+	if err == nil {
+		span.SetAttributes(attribute.Bool("cache.hit", found))
+	}
+	endSpan(span, err)
+	return value, found, err
+}
+
+func (t *TracingRepo) Delete(ctx context.Context, key string) error {
+	ctx, span := t.start(ctx, "Delete", t.keyAttribute(key))
+	err := t.repo.Delete(ctx, key)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) Increment(ctx context.Context, key string) (int64, error) {
+	ctx, span := t.start(ctx, "Increment", t.keyAttribute(key))
+	val, err := t.repo.Increment(ctx, key)
+	endSpan(span, err)
+	return val, err
+}
+
+func (t *TracingRepo) IncrementWithTTL(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	ctx, span := t.start(ctx, "IncrementWithTTL", t.keyAttribute(key))
+	val, err := t.repo.IncrementWithTTL(ctx, key, exp)
+	endSpan(span, err)
+	return val, err
+}
+
+func (t *TracingRepo) LPush(ctx context.Context, key string, value []byte) error {
+	ctx, span := t.start(ctx, "LPush", t.keyAttribute(key))
+	err := t.repo.LPush(ctx, key, value)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) LRange(ctx context.Context, key string, start int64, end int64) ([]string, error) {
+	ctx, span := t.start(ctx, "LRange", t.keyAttribute(key))
+	values, err := t.repo.LRange(ctx, key, start, end)
+	endSpan(span, err)
+	return values, err
+}
+
+func (t *TracingRepo) LTrim(ctx context.Context, key string, start int64, end int64) error {
+	ctx, span := t.start(ctx, "LTrim", t.keyAttribute(key))
+	err := t.repo.LTrim(ctx, key, start, end)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) LRem(ctx context.Context, key string, count int64, value []byte) error {
+	ctx, span := t.start(ctx, "LRem", t.keyAttribute(key))
+	err := t.repo.LRem(ctx, key, count, value)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TracingRepo) KeysByPattern(ctx context.Context, pattern string) ([]string, error) {
+	var attrs []attribute.KeyValue
+	if !t.hashKeys {
+		attrs = append(attrs, attribute.String("cache.pattern", pattern))
+	}
+	ctx, span := t.start(ctx, "KeysByPattern", attrs...)
+	keys, err := t.repo.KeysByPattern(ctx, pattern)
+	endSpan(span, err)
+	return keys, err
+}
+
+// ScanKeys opens a span for every page.
+func (t *TracingRepo) ScanKeys(ctx context.Context, pattern string, batchSize int) *KeyIterator {
+	var attrs []attribute.KeyValue
+	if !t.hashKeys {
+		attrs = append(attrs, attribute.String("cache.pattern", pattern))
+	}
+	return wrapPages(ScanKeys(ctx, t.repo, pattern, batchSize), func(ctx context.Context, cursor uint64, page scanPageFunc) ([]string, uint64, error) {
+		ctx, span := t.start(ctx, "ScanKeys", attrs...)
+		keys, next, err := page(ctx, cursor)
+		if err == nil {
+			span.SetAttributes(attribute.Int("cache.key_count", len(keys)))
+		}
+		endSpan(span, err)
+		return keys, next, err
+	})
+}
+
+func (t *TracingRepo) ValuesByKeys(ctx context.Context, keys []string) ([]interface{}, error) {
+	ctx, span := t.start(ctx, "ValuesByKeys", attribute.Int("cache.key_count", len(keys)))
+	values, err := t.repo.ValuesByKeys(ctx, keys)
+	if err == nil {
+		hits := 0
+		for _, v := range values {
+			if v != nil {
+				hits++
+			}
+		}
+		span.SetAttributes(attribute.Int("cache.hit_count", hits))
+	}
+	endSpan(span, err)
+	return values, err
+}
+
+func (t *TracingRepo) GetMany(ctx context.Context, keys []string) ([]Result, error) {
+	ctx, span := t.start(ctx, "GetMany", attribute.Int("cache.key_count", len(keys)))
+	results, err := GetMany(ctx, t.repo, keys)
+	if err == nil {
+		hits := 0
+		for _, result := range results {
+			if result.Found {
+				hits++
+			}
+		}
+		span.SetAttributes(attribute.Int("cache.hit_count", hits))
+	}
+	endSpan(span, err)
+	return results, err
+}
+
+func (t *TracingRepo) Unwrap() CacheRepo {
+	return t.repo
+}
+
+func (t *TracingRepo) Close() error {
+	return t.repo.Close()
+}
+
+func (t *TracingRepo) Ping(ctx context.Context) error {
+	ctx, span := t.start(ctx, "Ping")
+	err := t.repo.Ping(ctx)
+	endSpan(span, err)
+	return err
+}
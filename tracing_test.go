@@ -0,0 +1,167 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTracing(t *testing.T, opts TracingOptions) (*TracingRepo, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	opts.TracerProvider = provider
+	return NewTracingRepo(NewMemoryCache(), opts), exporter
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingRepo(t *testing.T) {
+	ctx := context.Background()
+	repo, exporter := setupTracing(t, TracingOptions{System: "memory"})
+
+	require.NoError(t, repo.Store(ctx, "user:1", []byte("a"), time.Minute))
+	_, _, _ = repo.Get(ctx, "user:1")
+	_, _, _ = repo.Get(ctx, "user:2")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "cache.Store", spans[0].Name)
+
+	attrs := spanAttributes(spans[1])
+	assert.Equal(t, "cache.Get", spans[1].Name)
+	assert.Equal(t, "memory", attrs["db.system"].AsString())
+	assert.Equal(t, "Get", attrs["db.operation"].AsString())
+	assert.Equal(t, "user:1", attrs["cache.key"].AsString())
+	assert.True(t, attrs["cache.hit"].AsBool())
+	assert.False(t, spanAttributes(spans[2])["cache.hit"].AsBool())
+}
+
+func TestTracingRepo_ScanKeys(t *testing.T) {
+	ctx := context.Background()
+	repo, exporter := setupTracing(t, TracingOptions{System: "memory"})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.Store(ctx, fmt.Sprintf("user:%d", i), []byte("a"), time.Minute))
+	}
+	exporter.Reset()
+
+	it := ScanKeys(ctx, repo, "user:*", 2)
+	for it.Next() {
+	}
+	require.NoError(t, it.Err())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2, "one span per page")
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "cache.ScanKeys", spans[0].Name)
+	assert.Equal(t, "user:*", attrs["cache.pattern"].AsString())
+	assert.Equal(t, int64(2), attrs["cache.key_count"].AsInt64())
+}
+
+func TestTracingRepo_HashKeys(t *testing.T) {
+	ctx := context.Background()
+	repo, exporter := setupTracing(t, TracingOptions{System: "memory", HashKeys: true})
+
+	_, _, _ = repo.Get(ctx, "user:jane@example.com")
+
+	attrs := spanAttributes(exporter.GetSpans()[0])
+	_, hasKey := attrs["cache.key"]
+	assert.False(t, hasKey)
+	assert.Len(t, attrs["cache.key_hash"].AsString(), 64)
+}
+
+func TestGetFromCache_Spans(t *testing.T) {
+	ctx := context.Background()
+	repo, exporter := setupTracing(t, TracingOptions{System: "memory"})
+
+	_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	})
+	require.NoError(t, err)
+
+	// Children end before their parent
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	names := []string{spans[0].Name, spans[1].Name, spans[2].Name, spans[3].Name}
+	assert.Equal(t, []string{"cache.Get", "GetFromCache.load", "cache.Store", "GetFromCache"}, names)
+
+	parent := spans[3].SpanContext.SpanID()
+	for _, child := range spans[:3] {
+		assert.Equal(t, parent, child.Parent.SpanID(), "%s should be a child of GetFromCache", child.Name)
+	}
+}
+
+func TestGetFromCache_SpansThroughDecorators(t *testing.T) {
+	ctx := context.Background()
+	tracing, exporter := setupTracing(t, TracingOptions{System: "memory"})
+	repo := NewRetryingRepo(tracing, RetryOptions{})
+
+	_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	assert.Equal(t, "GetFromCache.load", spans[1].Name)
+	assert.Equal(t, "GetFromCache", spans[3].Name)
+}
+
+func TestGetFromCache_SpansWithHashedKeys(t *testing.T) {
+	ctx := context.Background()
+	tracing, exporter := setupTracing(t, TracingOptions{System: "memory"})
+	repo, err := NewEncryptingRepo(tracing, EncryptionOptions{
+		Keys:          map[string][]byte{"v1": testKeyV1},
+		ActiveKeyID:   "v1",
+		KeyHashSecret: []byte("secret"),
+	})
+	require.NoError(t, err)
+
+	_, err = GetFromCache(ctx, repo, 1, "user", time.Minute, func(ctx context.Context, id int) (*TestData, error) {
+		return &TestData{ID: id}, nil
+	})
+	require.NoError(t, err)
+
+	// Every span carries the hashed key the TracingRepo receives
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	hashed := spanAttributes(spans[0])["cache.key"].AsString()
+	assert.Len(t, hashed, 64)
+	for _, span := range spans {
+		assert.Equal(t, hashed, spanAttributes(span)["cache.key"].AsString(), span.Name)
+	}
+}
+
+func TestGetFromCache_LoaderErrorSpan(t *testing.T) {
+	ctx := context.Background()
+	repo, exporter := setupTracing(t, TracingOptions{System: "memory"})
+
+	_, err := GetFromCache(ctx, repo, 1, "test", time.Minute, func(ctx context.Context, id int) (*TestData, error) {
+		return nil, errors.New("source error")
+	})
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "GetFromCache.load", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, codes.Error, spans[2].Status.Code)
+}
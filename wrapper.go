@@ -24,7 +24,9 @@ func GetFromCache[TData any, TId any](
 		entry = logrus.WithField("key", key)
 	)
 
+	ctx, endCall := startCall(ctx, repo, "GetFromCache", key)
 	defer func() {
+		endCall(err)
 		if err != nil {
 			entry = entry.WithField("err", err.Error())
 			entry.Errorf("GetFromCache got err")
@@ -42,7 +44,7 @@ func GetFromCache[TData any, TId any](
 
 	// not found or err
 	// 1. get from source
-	dataFromSource, err := fnCacheable(ctx, id)
+	dataFromSource, err := loadFromSource(ctx, repo, key, id, fnCacheable)
 	if err != nil {
 		entry = entry.WithField("err_type", "call fnCacheable")
 		return nil, err
@@ -88,7 +90,9 @@ func GetFromCacheWithDynamicTTL[TData any, TId any](
 		entry = logrus.WithField("key", key)
 	)
 
+	ctx, endCall := startCall(ctx, repo, "GetFromCache", key)
 	defer func() {
+		endCall(err)
 		if err != nil {
 			entry = entry.WithField("err", err.Error())
 			entry.Errorf("GetFromCache got err")
@@ -106,7 +110,7 @@ func GetFromCacheWithDynamicTTL[TData any, TId any](
 
 	// not found or err
 	// 1. get from source
-	dataFromSource, err := fnCacheable(ctx, id)
+	dataFromSource, err := loadFromSource(ctx, repo, key, id, fnCacheable)
 	if err != nil {
 		entry = entry.WithField("err_type", "call fnCacheable")
 		return nil, err
@@ -138,3 +142,64 @@ func GetFromCacheWithDynamicTTL[TData any, TId any](
 
 	return dataFromSource, nil
 }
+
+// loadFromSource calls fnCacheable in its own span when repo has a
+// CallTracer, and reports the call to every LoaderObserver among repo and the
+// repos it decorates, with the key as each of them receives it.
+func loadFromSource[TData any, TId any](
+	ctx context.Context,
+	repo CacheRepo,
+	key string,
+	id TId,
+	fnCacheable func(ctx context.Context, id TId) (*TData, error),
+) (*TData, error) {
+	ctx, endCall := startCall(ctx, repo, "GetFromCache.load", key)
+	start := time.Now()
+	data, err := fnCacheable(ctx, id)
+	endCall(err)
+
+	duration := time.Since(start)
+	walkDecorators(repo, key, func(repo CacheRepo, key string) bool {
+		if observer, ok := repo.(LoaderObserver); ok {
+			observer.ObserveLoader(ctx, key, duration, err)
+		}
+		return true
+	})
+	return data, err
+}
+
+// startCall opens a span with the first CallTracer among repo and the repos
+// it decorates.
+func startCall(ctx context.Context, repo CacheRepo, name string, key string) (context.Context, func(err error)) {
+	startCtx, end := ctx, func(err error) {}
+	walkDecorators(repo, key, func(repo CacheRepo, key string) bool {
+		tracer, ok := repo.(CallTracer)
+		if ok {
+			startCtx, end = tracer.StartCall(ctx, name, key)
+		}
+		return !ok
+	})
+	return startCtx, end
+}
+
+// keyMapper is implemented by decorators storing keys in another form, such
+// as NamespacedRepo.
+type keyMapper interface {
+	key(key string) string
+}
+
+// walkDecorators calls fn with repo and then every repo it decorates, until
+// fn returns false. Each gets key in the form it receives it in, so hooks
+// below an EncryptingRepo hashing keys never see the plain key.
+func walkDecorators(repo CacheRepo, key string, fn func(repo CacheRepo, key string) bool) {
+	for repo != nil && fn(repo, key) {
+		if mapper, ok := repo.(keyMapper); ok {
+			key = mapper.key(key)
+		}
+		u, ok := repo.(Unwrapper)
+		if !ok {
+			return
+		}
+		repo = u.Unwrap()
+	}
+}
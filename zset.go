@@ -0,0 +1,38 @@
+package cache_go
+
+import (
+	"context"
+)
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSetStore is implemented by backends that store sorted sets under a
+// key, like Redis. Members are ordered by score, then lexicographically.
+// Score bounds are inclusive; use math.Inf for open ends. Ranks start at 0,
+// and negative rank indexes count from the end. Removing the last member
+// deletes the set.
+type SortedSetStore interface {
+	// ZAdd adds members or updates their scores, and returns how many were
+	// new.
+	ZAdd(ctx context.Context, key string, members ...ZMember) (int64, error)
+	// ZIncrBy adds incr to the score of member, 0 if missing, and returns the
+	// new score.
+	ZIncrBy(ctx context.Context, key string, member string, incr float64) (float64, error)
+	// ZRangeByScore returns the members scored between min and max, lowest
+	// first.
+	ZRangeByScore(ctx context.Context, key string, min, max float64) ([]string, error)
+	// ZRevRangeWithScores returns the members ranked start to stop from the
+	// highest score, such as a top 10 with 0 and 9.
+	ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) ([]ZMember, error)
+	// ZRank returns the rank of member from the lowest score.
+	ZRank(ctx context.Context, key string, member string) (int64, bool, error)
+	// ZRem removes members and returns how many existed.
+	ZRem(ctx context.Context, key string, members ...string) (int64, error)
+	// ZRemRangeByScore removes the members scored between min and max and
+	// returns how many there were.
+	ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int64, error)
+}
@@ -0,0 +1,106 @@
+package cache_go
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSortedSetStore runs the SortedSetStore behaviour shared by every
+// backend.
+func testSortedSetStore(t *testing.T, store SortedSetStore, repo CacheRepo) {
+	ctx := context.Background()
+	for _, key := range []string{"zset:board", "zset:activity", "zset:string"} {
+		defer repo.Delete(ctx, key)
+	}
+
+	added, err := store.ZAdd(ctx, "zset:board", ZMember{"ann", 10}, ZMember{"bob", 30}, ZMember{"cid", 20})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), added)
+	added, err = store.ZAdd(ctx, "zset:board", ZMember{"ann", 25}, ZMember{"dan", 20})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), added)
+
+	score, err := store.ZIncrBy(ctx, "zset:board", "cid", 15)
+	require.NoError(t, err)
+	assert.Equal(t, 35.0, score)
+	score, err = store.ZIncrBy(ctx, "zset:board", "eve", 1.5)
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, score)
+
+	top, err := store.ZRevRangeWithScores(ctx, "zset:board", 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []ZMember{{"cid", 35}, {"bob", 30}, {"ann", 25}}, top)
+	bottom, err := store.ZRevRangeWithScores(ctx, "zset:board", -2, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ZMember{{"dan", 20}, {"eve", 1.5}}, bottom)
+	none, err := store.ZRevRangeWithScores(ctx, "zset:missing", 0, -1)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	rank, found, err := store.ZRank(ctx, "zset:board", "ann")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(2), rank)
+	_, found, err = store.ZRank(ctx, "zset:board", "zed")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	removed, err := store.ZRem(ctx, "zset:board", "eve", "zed")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	rank, _, _ = store.ZRank(ctx, "zset:board", "dan")
+	assert.Equal(t, int64(0), rank)
+
+	// Recent activity: members scored by Unix time.
+	_, err = store.ZAdd(ctx, "zset:activity", ZMember{"a", 100}, ZMember{"b", 200}, ZMember{"c", 200}, ZMember{"d", 300})
+	require.NoError(t, err)
+	members, err := store.ZRangeByScore(ctx, "zset:activity", 200, math.Inf(1))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, members)
+	members, err = store.ZRangeByScore(ctx, "zset:activity", math.Inf(-1), 150)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, members)
+	members, err = store.ZRangeByScore(ctx, "zset:missing", math.Inf(-1), math.Inf(1))
+	require.NoError(t, err)
+	assert.Empty(t, members)
+
+	removed, err = store.ZRemRangeByScore(ctx, "zset:activity", math.Inf(-1), 200)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+	removed, err = store.ZRem(ctx, "zset:activity", "d")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, found, err = repo.Get(ctx, "zset:activity")
+	require.NoError(t, err)
+	assert.False(t, found, "an emptied sorted set is deleted")
+
+	require.NoError(t, repo.Store(ctx, "zset:string", []byte("plain"), time.Minute))
+	_, err = store.ZAdd(ctx, "zset:string", ZMember{"a", 1})
+	assert.True(t, errors.Is(err, ErrWrongType), "ZAdd: %v", err)
+	_, _, err = store.ZRank(ctx, "zset:string", "a")
+	assert.True(t, errors.Is(err, ErrWrongType), "ZRank: %v", err)
+	_, err = store.ZRangeByScore(ctx, "zset:string", 0, 1)
+	assert.True(t, errors.Is(err, ErrWrongType), "ZRangeByScore: %v", err)
+}
+
+func TestMemoryCache_SortedSetStore(t *testing.T) {
+	cache := NewMemoryCache()
+	testSortedSetStore(t, cache, cache)
+
+	ctx := context.Background()
+	_, err := cache.ZAdd(ctx, "zset", ZMember{"a", math.NaN()})
+	assert.Error(t, err)
+
+	// Sorted sets aren't strings or sets.
+	cache.ZAdd(ctx, "zset", ZMember{"a", 1})
+	_, _, err = cache.Get(ctx, "zset")
+	assert.ErrorIs(t, err, ErrWrongType)
+	_, err = cache.SMembers(ctx, "zset")
+	assert.ErrorIs(t, err, ErrWrongType)
+}